@@ -20,6 +20,7 @@ import (
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator"
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/fluentbit"
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/otel"
+	"github.com/GoogleCloudPlatform/ops-agent/discovery"
 )
 
 type MetricsReceiverApache struct {
@@ -176,42 +177,48 @@ func (p LoggingProcessorApacheError) Components(tag string, uid string) []fluent
 	return c
 }
 
+// apacheAccessLogPaths are the default log paths of the apache_access receiver.
+var apacheAccessLogPaths = []string{
+	// Default log file path on Debian / Ubuntu
+	"/var/log/apache2/access.log",
+	// Default log file path RHEL / CentOS
+	"/var/log/apache2/access_log",
+	// Default log file path SLES
+	"/var/log/httpd/access_log",
+}
+
 type LoggingReceiverApacheAccess struct {
 	LoggingProcessorApacheAccess            `yaml:",inline"`
-	confgenerator.LoggingReceiverFilesMixin `yaml:",inline" validate:"structonly"`
+	confgenerator.LoggingReceiverFilesMixin `yaml:",inline"`
 }
 
 func (r LoggingReceiverApacheAccess) Components(tag string) []fluentbit.Component {
 	if len(r.IncludePaths) == 0 {
-		r.IncludePaths = []string{
-			// Default log file path on Debian / Ubuntu
-			"/var/log/apache2/access.log",
-			// Default log file path RHEL / CentOS
-			"/var/log/apache2/access_log",
-			// Default log file path SLES
-			"/var/log/httpd/access_log",
-		}
+		r.IncludePaths = apacheAccessLogPaths
 	}
 	c := r.LoggingReceiverFilesMixin.Components(tag)
 	c = append(c, r.LoggingProcessorApacheAccess.Components(tag, "apache_access")...)
 	return c
 }
 
+// apacheErrorLogPaths are the default log paths of the apache_error receiver.
+var apacheErrorLogPaths = []string{
+	// Default log file path on Debian / Ubuntu
+	"/var/log/apache2/error.log",
+	// Default log file path RHEL / CentOS
+	"/var/log/apache2/error_log",
+	// Default log file path SLES
+	"/var/log/httpd/error_log",
+}
+
 type LoggingReceiverApacheError struct {
 	LoggingProcessorApacheError             `yaml:",inline"`
-	confgenerator.LoggingReceiverFilesMixin `yaml:",inline" validate:"structonly"`
+	confgenerator.LoggingReceiverFilesMixin `yaml:",inline"`
 }
 
 func (r LoggingReceiverApacheError) Components(tag string) []fluentbit.Component {
 	if len(r.IncludePaths) == 0 {
-		r.IncludePaths = []string{
-			// Default log file path on Debian / Ubuntu
-			"/var/log/apache2/error.log",
-			// Default log file path RHEL / CentOS
-			"/var/log/apache2/error_log",
-			// Default log file path SLES
-			"/var/log/httpd/error_log",
-		}
+		r.IncludePaths = apacheErrorLogPaths
 	}
 	c := r.LoggingReceiverFilesMixin.Components(tag)
 	c = append(c, r.LoggingProcessorApacheError.Components(tag, "apache_error")...)
@@ -224,3 +231,41 @@ func init() {
 	confgenerator.LoggingReceiverTypes.RegisterType(func() confgenerator.Component { return &LoggingReceiverApacheAccess{} })
 	confgenerator.LoggingReceiverTypes.RegisterType(func() confgenerator.Component { return &LoggingReceiverApacheError{} })
 }
+
+func init() {
+	discovery.Rules.Register(discovery.Rule{
+		Name: "apache",
+		Match: func(p discovery.Process) bool {
+			return p.Name == "apache2" || p.Name == "httpd"
+		},
+		MetricsReceiver: func(d discovery.Detection) confgenerator.MetricsReceiver {
+			return &MetricsReceiverApache{
+				ConfigComponent:       confgenerator.ConfigComponent{Type: "apache"},
+				MetricsReceiverShared: confgenerator.MetricsReceiverShared{CollectionInterval: "60s"},
+				ServerStatusURL:       fmt.Sprintf("http://localhost:%d/server-status?auto", d.Port(80)),
+			}
+		},
+		Logs: []discovery.LogSource{
+			{
+				Type:  "apache_access",
+				Paths: apacheAccessLogPaths,
+				Receiver: func(includePaths []string) confgenerator.LoggingReceiver {
+					return &LoggingReceiverApacheAccess{
+						LoggingProcessorApacheAccess: LoggingProcessorApacheAccess{ConfigComponent: confgenerator.ConfigComponent{Type: "apache_access"}},
+						LoggingReceiverFilesMixin:    confgenerator.LoggingReceiverFilesMixin{IncludePaths: includePaths},
+					}
+				},
+			},
+			{
+				Type:  "apache_error",
+				Paths: apacheErrorLogPaths,
+				Receiver: func(includePaths []string) confgenerator.LoggingReceiver {
+					return &LoggingReceiverApacheError{
+						LoggingProcessorApacheError: LoggingProcessorApacheError{ConfigComponent: confgenerator.ConfigComponent{Type: "apache_error"}},
+						LoggingReceiverFilesMixin:   confgenerator.LoggingReceiverFilesMixin{IncludePaths: includePaths},
+					}
+				},
+			},
+		},
+	})
+}
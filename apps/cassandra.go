@@ -21,6 +21,7 @@ import (
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator"
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/fluentbit"
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/otel"
+	"github.com/GoogleCloudPlatform/ops-agent/discovery"
 )
 
 type MetricsReceiverCassandra struct {
@@ -98,7 +99,7 @@ func (LoggingProcessorCassandraSystem) Type() string {
 }
 
 func (p LoggingProcessorCassandraSystem) Components(tag string, uid string) []fluentbit.Component {
-	return javaLogParsingComponents(tag, uid)
+	return javaLogParsingComponents(tag, uid, cassandraLogParser, cassandraLogLineStart)
 }
 
 type LoggingProcessorCassandraDebug struct {
@@ -110,43 +111,50 @@ func (LoggingProcessorCassandraDebug) Type() string {
 }
 
 func (p LoggingProcessorCassandraDebug) Components(tag string, uid string) []fluentbit.Component {
-	return javaLogParsingComponents(tag, uid)
+	return javaLogParsingComponents(tag, uid, cassandraLogParser, cassandraLogLineStart)
 }
 
-func javaLogParsingComponents(tag string, uid string) []fluentbit.Component {
+var cassandraLogParser = confgenerator.RegexParser{
+	// Sample line: INFO [IndexSummaryManager:1] 2021-10-07 12:57:05,003 IndexSummaryRedistribution.java:83 - Redistributing index summaries
+	// Sample line: WARN [main] 2021-10-07 11:57:01,602 StartupChecks.java:329 - Maximum number of memory map areas per process (vm.max_map_count) 65530 is too low, recommended value: 1048575, you can change it with sysctl.
+	// Sample line: ERROR [MemtablePostFlush:2] 2021-10-05 01:03:35,424 CassandraDaemon.java:579 - Exception in thread Thread[MemtablePostFlush:2,5,main]
+	// 				org.apache.cassandra.io.FSReadError: java.io.IOException: Invalid folder descriptor trying to create log replica /folder/views-9786ac1cdd583201a7cdad556410c985
+	// 					at org.apache.cassandra.db.lifecycle.LogReplica.create(LogReplica.java:59)
+	// 					at org.apache.cassandra.db.lifecycle.LogReplicaSet.maybeCreateReplica(LogReplicaSet.java:87)
+	// 					at org.apache.cassandra.db.lifecycle.LogFile.makeAddRecord(LogFile.java:336)
+	// 					at org.apache.cassandra.db.lifecycle.LogFile.add(LogFile.java:310)
+	Regex: `^(?<level>[A-Z]+)\s+\[(?<module>[^\]]+)\]\s+(?<time>\d{4}-\d{2}-\d{2}\s+\d{2}:\d{2}:\d{2},\d+)\s+(?<message>(?:(?<javaClass>[\w\.]+):(?<lineNumber>\d+))?[\S\s]+)`,
+	Parser: confgenerator.ParserShared{
+		TimeKey:    "time",
+		TimeFormat: "%Y-%m-%d %H:%M:%S,%L",
+		Types: map[string]string{
+			"lineNumber": "integer",
+		},
+	},
+}
+
+// cassandraLogLineStart matches the beginning of the first line of a Cassandra log entry.
+const cassandraLogLineStart = `[A-Z]+\s+\[[^\]]+\] \d+`
+
+// javaLogParsingComponents parses log entries written by a Java logging framework such as log4j or logback.
+// Entries start with a line matching lineStart and are parsed with parser; any following lines that do
+// not match lineStart (e.g. stack traces) are appended to the entry. The parser must capture the log
+// level in a "level" field.
+func javaLogParsingComponents(tag string, uid string, parser confgenerator.RegexParser, lineStart string) []fluentbit.Component {
 	c := confgenerator.LoggingProcessorParseMultilineRegex{
 		LoggingProcessorParseRegexComplex: confgenerator.LoggingProcessorParseRegexComplex{
-			Parsers: []confgenerator.RegexParser{
-				{
-					// Sample line: INFO [IndexSummaryManager:1] 2021-10-07 12:57:05,003 IndexSummaryRedistribution.java:83 - Redistributing index summaries
-					// Sample line: WARN [main] 2021-10-07 11:57:01,602 StartupChecks.java:329 - Maximum number of memory map areas per process (vm.max_map_count) 65530 is too low, recommended value: 1048575, you can change it with sysctl.
-					// Sample line: ERROR [MemtablePostFlush:2] 2021-10-05 01:03:35,424 CassandraDaemon.java:579 - Exception in thread Thread[MemtablePostFlush:2,5,main]
-					// 				org.apache.cassandra.io.FSReadError: java.io.IOException: Invalid folder descriptor trying to create log replica /folder/views-9786ac1cdd583201a7cdad556410c985
-					// 					at org.apache.cassandra.db.lifecycle.LogReplica.create(LogReplica.java:59)
-					// 					at org.apache.cassandra.db.lifecycle.LogReplicaSet.maybeCreateReplica(LogReplicaSet.java:87)
-					// 					at org.apache.cassandra.db.lifecycle.LogFile.makeAddRecord(LogFile.java:336)
-					// 					at org.apache.cassandra.db.lifecycle.LogFile.add(LogFile.java:310)
-					Regex: `^(?<level>[A-Z]+)\s+\[(?<module>[^\]]+)\]\s+(?<time>\d{4}-\d{2}-\d{2}\s+\d{2}:\d{2}:\d{2},\d+)\s+(?<message>(?:(?<javaClass>[\w\.]+):(?<lineNumber>\d+))?[\S\s]+)`,
-					Parser: confgenerator.ParserShared{
-						TimeKey:    "time",
-						TimeFormat: "%Y-%m-%d %H:%M:%S,%L",
-						Types: map[string]string{
-							"lineNumber": "integer",
-						},
-					},
-				},
-			},
+			Parsers: []confgenerator.RegexParser{parser},
 		},
 		Rules: []confgenerator.MultilineRule{
 			{
 				StateName: "start_state",
 				NextState: "cont",
-				Regex:     `[A-Z]+\s+\[[^\]]+\] \d+`,
+				Regex:     lineStart,
 			},
 			{
 				StateName: "cont",
 				NextState: "cont",
-				Regex:     `^(?![A-Z]+\s+\[[^\]]+\] \d+)`,
+				Regex:     fmt.Sprintf(`^(?!%s)`, lineStart),
 			},
 		},
 	}.Components(tag, uid)
@@ -216,54 +224,63 @@ func (p LoggingProcessorCassandraGC) Components(tag string, uid string) []fluent
 	return c
 }
 
+// cassandraSystemLogPaths are the default log paths of the cassandra_system receiver.
+var cassandraSystemLogPaths = []string{
+	// Default log file path on Debian / Ubuntu / RHEL / CentOS
+	"/var/log/cassandra/system*.log",
+	// No default install position / log path for SLES
+}
+
 type LoggingReceiverCassandraSystem struct {
 	LoggingProcessorCassandraSystem         `yaml:",inline"`
-	confgenerator.LoggingReceiverFilesMixin `yaml:",inline" validate:"structonly"`
+	confgenerator.LoggingReceiverFilesMixin `yaml:",inline"`
 }
 
 func (r LoggingReceiverCassandraSystem) Components(tag string) []fluentbit.Component {
 	if len(r.IncludePaths) == 0 {
-		r.IncludePaths = []string{
-			// Default log file path on Debian / Ubuntu / RHEL / CentOS
-			"/var/log/cassandra/system*.log",
-			// No default install position / log path for SLES
-		}
+		r.IncludePaths = cassandraSystemLogPaths
 	}
 	c := r.LoggingReceiverFilesMixin.Components(tag)
 	c = append(c, r.LoggingProcessorCassandraSystem.Components(tag, "cassandra_system")...)
 	return c
 }
 
+// cassandraDebugLogPaths are the default log paths of the cassandra_debug receiver.
+var cassandraDebugLogPaths = []string{
+	// Default log file path on Debian / Ubuntu / RHEL / CentOS
+	"/var/log/cassandra/debug*.log",
+	// No default install position / log path for SLES
+}
+
 type LoggingReceiverCassandraDebug struct {
 	LoggingProcessorCassandraDebug          `yaml:",inline"`
-	confgenerator.LoggingReceiverFilesMixin `yaml:",inline" validate:"structonly"`
+	confgenerator.LoggingReceiverFilesMixin `yaml:",inline"`
 }
 
 func (r LoggingReceiverCassandraDebug) Components(tag string) []fluentbit.Component {
 	if len(r.IncludePaths) == 0 {
-		r.IncludePaths = []string{
-			// Default log file path on Debian / Ubuntu / RHEL / CentOS
-			"/var/log/cassandra/debug*.log",
-			// No default install position / log path for SLES
-		}
+		r.IncludePaths = cassandraDebugLogPaths
 	}
 	c := r.LoggingReceiverFilesMixin.Components(tag)
 	c = append(c, r.LoggingProcessorCassandraDebug.Components(tag, "cassandra_debug")...)
 	return c
 }
 
+// cassandraGCLogPaths are the default log paths of the cassandra_gc receiver.
+var cassandraGCLogPaths = []string{
+	// Default log file path on Debian / Ubuntu / RHEL / CentOS
+	"/var/log/cassandra/gc.log.*.current",
+	// No default install position / log path for SLES
+}
+
 type LoggingReceiverCassandraGC struct {
 	LoggingProcessorCassandraGC             `yaml:",inline"`
-	confgenerator.LoggingReceiverFilesMixin `yaml:",inline" validate:"structonly"`
+	confgenerator.LoggingReceiverFilesMixin `yaml:",inline"`
 }
 
 func (r LoggingReceiverCassandraGC) Components(tag string) []fluentbit.Component {
 	if len(r.IncludePaths) == 0 {
-		r.IncludePaths = []string{
-			// Default log file path on Debian / Ubuntu / RHEL / CentOS
-			"/var/log/cassandra/gc.log.*.current",
-			// No default install position / log path for SLES
-		}
+		r.IncludePaths = cassandraGCLogPaths
 	}
 	c := r.LoggingReceiverFilesMixin.Components(tag)
 	c = append(c, r.LoggingProcessorCassandraGC.Components(tag, "cassandra_gc")...)
@@ -278,3 +295,56 @@ func init() {
 	confgenerator.LoggingReceiverTypes.RegisterType(func() confgenerator.Component { return &LoggingReceiverCassandraDebug{} })
 	confgenerator.LoggingReceiverTypes.RegisterType(func() confgenerator.Component { return &LoggingReceiverCassandraGC{} })
 }
+
+func init() {
+	discovery.Rules.Register(discovery.Rule{
+		Name: "cassandra",
+		Match: func(p discovery.Process) bool {
+			return p.Name == "java" && p.HasArg("org.apache.cassandra.service.CassandraDaemon")
+		},
+		MetricsGroup: "jvm",
+		MetricsReceiver: func(d discovery.Detection) confgenerator.MetricsReceiver {
+			endpoint := defaultCassandraEndpoint
+			if port, ok := jmxPort(d.Process, "-Dcom.sun.management.jmxremote.port=", "-Dcassandra.jmx.local.port="); ok {
+				endpoint = fmt.Sprintf("localhost:%d", port)
+			}
+			return &MetricsReceiverCassandra{
+				ConfigComponent:       confgenerator.ConfigComponent{Type: "cassandra"},
+				MetricsReceiverShared: confgenerator.MetricsReceiverShared{CollectionInterval: "60s"},
+				Endpoint:              endpoint,
+			}
+		},
+		Logs: []discovery.LogSource{
+			{
+				Type:  "cassandra_system",
+				Paths: cassandraSystemLogPaths,
+				Receiver: func(includePaths []string) confgenerator.LoggingReceiver {
+					return &LoggingReceiverCassandraSystem{
+						LoggingProcessorCassandraSystem: LoggingProcessorCassandraSystem{ConfigComponent: confgenerator.ConfigComponent{Type: "cassandra_system"}},
+						LoggingReceiverFilesMixin:       confgenerator.LoggingReceiverFilesMixin{IncludePaths: includePaths},
+					}
+				},
+			},
+			{
+				Type:  "cassandra_debug",
+				Paths: cassandraDebugLogPaths,
+				Receiver: func(includePaths []string) confgenerator.LoggingReceiver {
+					return &LoggingReceiverCassandraDebug{
+						LoggingProcessorCassandraDebug: LoggingProcessorCassandraDebug{ConfigComponent: confgenerator.ConfigComponent{Type: "cassandra_debug"}},
+						LoggingReceiverFilesMixin:      confgenerator.LoggingReceiverFilesMixin{IncludePaths: includePaths},
+					}
+				},
+			},
+			{
+				Type:  "cassandra_gc",
+				Paths: cassandraGCLogPaths,
+				Receiver: func(includePaths []string) confgenerator.LoggingReceiver {
+					return &LoggingReceiverCassandraGC{
+						LoggingProcessorCassandraGC: LoggingProcessorCassandraGC{ConfigComponent: confgenerator.ConfigComponent{Type: "cassandra_gc"}},
+						LoggingReceiverFilesMixin:   confgenerator.LoggingReceiverFilesMixin{IncludePaths: includePaths},
+					}
+				},
+			},
+		},
+	})
+}
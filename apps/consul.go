@@ -0,0 +1,74 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apps
+
+import (
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator"
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/otel"
+)
+
+type MetricsReceiverConsul struct {
+	confgenerator.ConfigComponent `yaml:",inline"`
+
+	confgenerator.MetricsReceiverShared `yaml:",inline"`
+
+	confgenerator.MetricsReceiverSharedTLS `yaml:",inline"`
+
+	Endpoint string `yaml:"endpoint" validate:"omitempty,http_url"`
+	// An ACL token with agent:read permission. Only needed when ACLs are enabled.
+	Token string `yaml:"token" validate:"omitempty"`
+}
+
+// defaultConsulEndpoint is the agent metrics API, which serves Prometheus metrics when asked for format=prometheus.
+// Consul only populates it when telemetry.prometheus_retention_time is set.
+const defaultConsulEndpoint = "http://localhost:8500/v1/agent/metrics?format=prometheus"
+
+func (r MetricsReceiverConsul) Type() string {
+	return "consul"
+}
+
+func (r MetricsReceiverConsul) Pipelines() []otel.Pipeline {
+	if r.Endpoint == "" {
+		r.Endpoint = defaultConsulEndpoint
+	}
+
+	scrapeConfig := prometheusScrapeConfig("consul", r.CollectionIntervalString(), r.Endpoint, r.MetricsReceiverSharedTLS)
+	if r.Token != "" {
+		// Consul accepts ACL tokens as bearer tokens.
+		scrapeConfig["authorization"] = map[string]interface{}{
+			"credentials": r.Token,
+		}
+	}
+
+	return []otel.Pipeline{{
+		Receiver: prometheusScrapeReceiver(scrapeConfig),
+		Processors: []otel.Component{
+			// Consul also exports Go runtime and process metrics; only keep the Consul ones.
+			otel.MetricsFilter(
+				"include",
+				"regexp",
+				"^consul_",
+			),
+			otel.NormalizeSums(),
+			otel.MetricsTransform(
+				otel.AddPrefix("workload.googleapis.com"),
+			),
+		},
+	}}
+}
+
+func init() {
+	confgenerator.MetricsReceiverTypes.RegisterType(func() confgenerator.Component { return &MetricsReceiverConsul{} })
+}
@@ -0,0 +1,62 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apps
+
+import (
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator"
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/otel"
+)
+
+type MetricsReceiverEtcd struct {
+	confgenerator.ConfigComponent `yaml:",inline"`
+
+	confgenerator.MetricsReceiverShared `yaml:",inline"`
+
+	confgenerator.MetricsReceiverSharedTLS `yaml:",inline"`
+
+	Endpoint string `yaml:"endpoint" validate:"omitempty,http_url"`
+}
+
+const defaultEtcdEndpoint = "http://localhost:2379/metrics"
+
+func (r MetricsReceiverEtcd) Type() string {
+	return "etcd"
+}
+
+func (r MetricsReceiverEtcd) Pipelines() []otel.Pipeline {
+	if r.Endpoint == "" {
+		r.Endpoint = defaultEtcdEndpoint
+	}
+
+	return []otel.Pipeline{{
+		Receiver: prometheusScrapeReceiver(prometheusScrapeConfig("etcd", r.CollectionIntervalString(), r.Endpoint, r.MetricsReceiverSharedTLS)),
+		Processors: []otel.Component{
+			// etcd also exports Go runtime, process and gRPC metrics; only keep the etcd ones.
+			otel.MetricsFilter(
+				"include",
+				"regexp",
+				"^etcd_",
+			),
+			otel.NormalizeSums(),
+			otel.MetricsTransform(
+				otel.AddPrefix("workload.googleapis.com"),
+			),
+		},
+	}}
+}
+
+func init() {
+	confgenerator.MetricsReceiverTypes.RegisterType(func() confgenerator.Component { return &MetricsReceiverEtcd{} })
+}
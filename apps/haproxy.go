@@ -0,0 +1,190 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apps
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator"
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/fluentbit"
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/otel"
+)
+
+type MetricsReceiverHAProxy struct {
+	confgenerator.ConfigComponent `yaml:",inline"`
+
+	confgenerator.MetricsReceiverShared `yaml:",inline"`
+
+	// Either the stats URL (e.g. http://localhost:8404/stats) or the stats socket (e.g. file:///run/haproxy/admin.sock).
+	Endpoint string `yaml:"endpoint" validate:"omitempty,url"`
+}
+
+// defaultHAProxyEndpoint is the stats socket configured by the Debian / Ubuntu / CentOS / RHEL packages.
+const defaultHAProxyEndpoint = "file:///run/haproxy/admin.sock"
+
+func (r MetricsReceiverHAProxy) Type() string {
+	return "haproxy"
+}
+
+func (r MetricsReceiverHAProxy) Pipelines() []otel.Pipeline {
+	if r.Endpoint == "" {
+		r.Endpoint = defaultHAProxyEndpoint
+	}
+
+	return []otel.Pipeline{{
+		Receiver: otel.Component{
+			Type: "haproxy",
+			Config: map[string]interface{}{
+				"collection_interval": r.CollectionIntervalString(),
+				"endpoint":            r.Endpoint,
+			},
+		},
+		Processors: []otel.Component{
+			otel.NormalizeSums(),
+			otel.MetricsTransform(
+				otel.AddPrefix("workload.googleapis.com"),
+			),
+		},
+	}}
+}
+
+func init() {
+	confgenerator.MetricsReceiverTypes.RegisterType(func() confgenerator.Component { return &MetricsReceiverHAProxy{} })
+}
+
+type LoggingProcessorHAProxy struct {
+	confgenerator.ConfigComponent `yaml:",inline"`
+}
+
+func (LoggingProcessorHAProxy) Type() string {
+	return "haproxy"
+}
+
+func (p LoggingProcessorHAProxy) Components(tag string, uid string) []fluentbit.Component {
+	// HAProxy only logs through syslog, so lines are usually prefixed with the syslog timestamp and host, followed by "haproxy[<pid>]: ".
+	const syslogPrefix = `^(?:.*\s)?[\w.-]+\[(?<pid>\d+)\]:\s+`
+	// Fields shared by the HTTP and TCP log formats.
+	// Documentation: https://cbonte.github.io/haproxy-dconv/2.4/configuration.html#8.2
+	const clientFields = `(?<client_ip>\S+):(?<client_port>\d+) \[(?<time>[^\]]+)\] (?<frontend_name>\S+) (?<backend_name>[^/ ]+)/(?<server_name>\S+) `
+	const connectionFields = `(?<active_connections>\d+)/(?<frontend_connections>\d+)/(?<backend_connections>\d+)/(?<server_connections>\d+)/\+?(?<retries>\d+) (?<server_queue>\d+)/(?<backend_queue>\d+)`
+	parser := confgenerator.ParserShared{
+		TimeKey:    "time",
+		TimeFormat: "%d/%b/%Y:%H:%M:%S.%L",
+		Types: map[string]string{
+			"pid":                  "integer",
+			"client_port":          "integer",
+			"request_time":         "integer",
+			"queue_time":           "integer",
+			"connect_time":         "integer",
+			"response_time":        "integer",
+			"total_time":           "integer",
+			"bytes_read":           "integer",
+			"http_request_status":  "integer",
+			"active_connections":   "integer",
+			"frontend_connections": "integer",
+			"backend_connections":  "integer",
+			"server_connections":   "integer",
+			"retries":              "integer",
+			"server_queue":         "integer",
+			"backend_queue":        "integer",
+			// N.B. "http_request_responseSize" is a string containing an integer.
+			// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#HttpRequest.FIELDS.response_size
+		},
+	}
+	c := confgenerator.LoggingProcessorParseRegexComplex{
+		Parsers: []confgenerator.RegexParser{
+			{
+				// HTTP log format ("option httplog").
+				// Sample line: Feb  6 12:14:14 localhost haproxy[14389]: 10.0.1.2:33317 [06/Feb/2009:12:14:14.655] http-in static/srv1 10/0/30/69/109 200 2750 - - ---- 1/1/1/1/0 0/0 {1wt.eu} {} "GET /index.html HTTP/1.1"
+				Regex: syslogPrefix + clientFields +
+					`(?<request_time>-?\d+)/(?<queue_time>-?\d+)/(?<connect_time>-?\d+)/(?<response_time>-?\d+)/\+?(?<total_time>-?\d+) ` +
+					`(?<http_request_status>-?\d+) \+?(?<http_request_responseSize>\d+) (?<captured_request_cookie>\S+) (?<captured_response_cookie>\S+) (?<termination_state>\S+) ` +
+					connectionFields +
+					`(?: \{(?<captured_request_headers>[^}]*)\})?(?: \{(?<captured_response_headers>[^}]*)\})? ` +
+					`"(?<http_request_requestMethod>\S+)(?: +(?<http_request_requestUrl>[^\"]*?)(?: +(?<http_request_protocol>\S+))?)?"$`,
+				Parser: parser,
+			},
+			{
+				// TCP log format ("option tcplog").
+				// Sample line: Feb  6 12:12:56 localhost haproxy[14387]: 10.0.1.2:33313 [06/Feb/2009:12:12:51.443] fnt bck/srv1 0/0/5007 212 -- 0/0/0/0/3 0/0
+				Regex: syslogPrefix + clientFields +
+					`(?<queue_time>-?\d+)/(?<connect_time>-?\d+)/\+?(?<total_time>-?\d+) \+?(?<bytes_read>\d+) (?<termination_state>\S+) ` +
+					connectionFields + `$`,
+				Parser: parser,
+			},
+		},
+	}.Components(tag, uid)
+
+	// HAProxy logs "-" when a captured cookie is not set. Remove the field entirely when this happens.
+	for _, field := range []string{
+		"captured_request_cookie",
+		"captured_response_cookie",
+	} {
+		c = append(c, fluentbit.Component{
+			Kind: "FILTER",
+			Config: map[string]string{
+				"Name":      "modify",
+				"Match":     tag,
+				"Condition": fmt.Sprintf("Key_Value_Equals %s -", field),
+				"Remove":    field,
+			},
+		})
+	}
+	// Copy the client address to the httpRequest structure for the HTTP log format.
+	c = append(c, fluentbit.Component{
+		Kind: "FILTER",
+		Config: map[string]string{
+			"Name":      "modify",
+			"Match":     tag,
+			"Condition": "Key_exists http_request_requestMethod",
+			"Copy":      "client_ip http_request_remoteIp",
+		},
+	})
+	// Generate the httpRequest structure for the HTTP log format.
+	c = append(c, fluentbit.Component{
+		Kind: "FILTER",
+		Config: map[string]string{
+			"Name":          "nest",
+			"Match":         tag,
+			"Operation":     "nest",
+			"Wildcard":      "http_request_*",
+			"Nest_under":    "logging.googleapis.com/http_request",
+			"Remove_prefix": "http_request_",
+		},
+	})
+	return c
+}
+
+type LoggingReceiverHAProxy struct {
+	LoggingProcessorHAProxy                 `yaml:",inline"`
+	confgenerator.LoggingReceiverFilesMixin `yaml:",inline"`
+}
+
+func (r LoggingReceiverHAProxy) Components(tag string) []fluentbit.Component {
+	if len(r.IncludePaths) == 0 {
+		r.IncludePaths = []string{
+			// Default log path written by the rsyslog configuration shipped with the Debian / Ubuntu / CentOS / RHEL packages.
+			"/var/log/haproxy.log",
+		}
+	}
+	c := r.LoggingReceiverFilesMixin.Components(tag)
+	c = append(c, r.LoggingProcessorHAProxy.Components(tag, "haproxy")...)
+	return c
+}
+
+func init() {
+	confgenerator.LoggingProcessorTypes.RegisterType(func() confgenerator.Component { return &LoggingProcessorHAProxy{} })
+	confgenerator.LoggingReceiverTypes.RegisterType(func() confgenerator.Component { return &LoggingReceiverHAProxy{} })
+}
@@ -23,274 +23,481 @@ type MetricsReceiverHostmetrics struct {
 	confgenerator.ConfigComponent `yaml:",inline"`
 
 	confgenerator.MetricsReceiverShared `yaml:",inline"`
+
+	Scrapers *HostmetricsScrapers `yaml:"scrapers,omitempty"`
+
+	// ExtraMetrics enables the metrics that are not collected by default, such as inode usage and packet drops.
+	ExtraMetrics bool `yaml:"extra_metrics,omitempty"`
+}
+
+// HostmetricsScrapers holds the per-scraper settings of the hostmetrics receiver. Scrapers that are not set are enabled
+// with the receiver's collection interval.
+type HostmetricsScrapers struct {
+	CPU        *HostmetricsScraper           `yaml:"cpu,omitempty"`
+	Load       *HostmetricsScraper           `yaml:"load,omitempty"`
+	Memory     *HostmetricsScraper           `yaml:"memory,omitempty"`
+	Disk       *HostmetricsDiskScraper       `yaml:"disk,omitempty"`
+	Filesystem *HostmetricsFilesystemScraper `yaml:"filesystem,omitempty"`
+	Network    *HostmetricsNetworkScraper    `yaml:"network,omitempty"`
+	Paging     *HostmetricsScraper           `yaml:"paging,omitempty"`
+	Process    *HostmetricsProcessScraper    `yaml:"process,omitempty"`
+	Processes  *HostmetricsScraper           `yaml:"processes,omitempty"`
+}
+
+type HostmetricsScraper struct {
+	Enabled *bool `yaml:"enabled,omitempty"`
+	// CollectionInterval overrides the receiver's collection_interval for this scraper.
+	CollectionInterval string `yaml:"collection_interval,omitempty" validate:"omitempty,duration=10s"`
+	// MatchType applies to the include and exclude filters of the scraper, if it has any.
+	MatchType string `yaml:"match_type,omitempty" validate:"omitempty,oneof=strict regexp"`
+}
+
+type HostmetricsDiskScraper struct {
+	HostmetricsScraper `yaml:",inline"`
+	IncludeDevices     []string `yaml:"include_devices,omitempty"`
+	ExcludeDevices     []string `yaml:"exclude_devices,omitempty"`
+}
+
+type HostmetricsFilesystemScraper struct {
+	HostmetricsScraper `yaml:",inline"`
+	IncludeDevices     []string `yaml:"include_devices,omitempty"`
+	ExcludeDevices     []string `yaml:"exclude_devices,omitempty"`
+	IncludeMountPoints []string `yaml:"include_mount_points,omitempty"`
+	ExcludeMountPoints []string `yaml:"exclude_mount_points,omitempty"`
+}
+
+type HostmetricsNetworkScraper struct {
+	HostmetricsScraper `yaml:",inline"`
+	IncludeInterfaces  []string `yaml:"include_interfaces,omitempty"`
+	ExcludeInterfaces  []string `yaml:"exclude_interfaces,omitempty"`
+}
+
+type HostmetricsProcessScraper struct {
+	HostmetricsScraper `yaml:",inline"`
+	IncludeNames       []string `yaml:"include_names,omitempty"`
+	ExcludeNames       []string `yaml:"exclude_names,omitempty"`
 }
 
 func (r MetricsReceiverHostmetrics) Type() string {
 	return "hostmetrics"
 }
 
+// hostmetricsScraper is the otel config of a single scraper.
+type hostmetricsScraper struct {
+	name     string
+	settings *HostmetricsScraper
+	config   interface{}
+}
+
+// scrapers returns the config of all the scrapers, in a fixed order.
+func (r MetricsReceiverHostmetrics) scrapers() []hostmetricsScraper {
+	s := r.Scrapers
+	if s == nil {
+		s = &HostmetricsScrapers{}
+	}
+	disk := hostmetricsScraper{"disk", nil, struct{}{}}
+	if d := s.Disk; d != nil {
+		disk.settings = &d.HostmetricsScraper
+		disk.config = filterConfig(map[string]interface{}{
+			"include": hostmetricsFilter("devices", d.IncludeDevices, d.MatchType),
+			"exclude": hostmetricsFilter("devices", d.ExcludeDevices, d.MatchType),
+		})
+	}
+	filesystem := hostmetricsScraper{"filesystem", nil, struct{}{}}
+	if f := s.Filesystem; f != nil {
+		filesystem.settings = &f.HostmetricsScraper
+		filesystem.config = filterConfig(map[string]interface{}{
+			"include_devices":      hostmetricsFilter("devices", f.IncludeDevices, f.MatchType),
+			"exclude_devices":      hostmetricsFilter("devices", f.ExcludeDevices, f.MatchType),
+			"include_mount_points": hostmetricsFilter("mount_points", f.IncludeMountPoints, f.MatchType),
+			"exclude_mount_points": hostmetricsFilter("mount_points", f.ExcludeMountPoints, f.MatchType),
+		})
+	}
+	network := hostmetricsScraper{"network", nil, struct{}{}}
+	if n := s.Network; n != nil {
+		network.settings = &n.HostmetricsScraper
+		network.config = filterConfig(map[string]interface{}{
+			"include": hostmetricsFilter("interfaces", n.IncludeInterfaces, n.MatchType),
+			"exclude": hostmetricsFilter("interfaces", n.ExcludeInterfaces, n.MatchType),
+		})
+	}
+	process := hostmetricsScraper{"process", nil, struct{}{}}
+	if p := s.Process; p != nil {
+		process.settings = &p.HostmetricsScraper
+		process.config = filterConfig(map[string]interface{}{
+			"include": hostmetricsFilter("names", p.IncludeNames, p.MatchType),
+			"exclude": hostmetricsFilter("names", p.ExcludeNames, p.MatchType),
+		})
+	}
+	return []hostmetricsScraper{
+		{"cpu", s.CPU, struct{}{}},
+		{"load", s.Load, struct{}{}},
+		{"memory", s.Memory, struct{}{}},
+		disk,
+		filesystem,
+		network,
+		{"paging", s.Paging, struct{}{}},
+		process,
+		{"processes", s.Processes, struct{}{}},
+	}
+}
+
+// hostmetricsFilter returns the config of a hostmetrics receiver include/exclude filter, or nil if values is empty.
+func hostmetricsFilter(key string, values []string, matchType string) map[string]interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	if matchType == "" {
+		matchType = "strict"
+	}
+	return map[string]interface{}{
+		key:          values,
+		"match_type": matchType,
+	}
+}
+
+// filterConfig drops the unset filters from config, and returns an empty scraper config if none are set.
+func filterConfig(config map[string]interface{}) interface{} {
+	for k, v := range config {
+		if v.(map[string]interface{}) == nil {
+			delete(config, k)
+		}
+	}
+	if len(config) == 0 {
+		return struct{}{}
+	}
+	return config
+}
+
 func (r MetricsReceiverHostmetrics) Pipelines() []otel.Pipeline {
-	return []otel.Pipeline{{
-		Receiver: otel.Component{
-			Type: "hostmetrics",
-			Config: map[string]interface{}{
-				"collection_interval": r.CollectionIntervalString(),
-				"scrapers": map[string]interface{}{
-					"cpu":        struct{}{},
-					"load":       struct{}{},
-					"memory":     struct{}{},
-					"disk":       struct{}{},
-					"filesystem": struct{}{},
-					"network":    struct{}{},
-					"paging":     struct{}{},
-					"process":    struct{}{},
-					"processes":  struct{}{},
-				},
-			},
-		},
-		Processors: []otel.Component{
-			{
-				// perform custom transformations that aren't supported by the metricstransform processor
-				Type: "agentmetrics",
+	// Scrapers with different collection intervals need separate receivers, so each interval gets its own pipeline.
+	var intervals []string
+	scrapersByInterval := map[string]map[string]interface{}{}
+	for _, s := range r.scrapers() {
+		interval := r.CollectionIntervalString()
+		if s.settings != nil {
+			if s.settings.Enabled != nil && !*s.settings.Enabled {
+				continue
+			}
+			if s.settings.CollectionInterval != "" {
+				interval = s.settings.CollectionInterval
+			}
+		}
+		if _, ok := scrapersByInterval[interval]; !ok {
+			intervals = append(intervals, interval)
+			scrapersByInterval[interval] = map[string]interface{}{}
+		}
+		scrapersByInterval[interval][s.name] = s.config
+	}
+
+	var pipelines []otel.Pipeline
+	for _, interval := range intervals {
+		pipelines = append(pipelines, otel.Pipeline{
+			Receiver: otel.Component{
+				Type: "hostmetrics",
 				Config: map[string]interface{}{
-					// https://github.com/GoogleCloudPlatform/opentelemetry-operations-collector/blob/master/processor/agentmetricsprocessor/agentmetricsprocessor.go#L58
-					"blank_label_metrics": []string{
-						"system.cpu.utilization",
-					},
+					"collection_interval": interval,
+					"scrapers":            scrapersByInterval[interval],
 				},
 			},
-			otel.MetricsFilter(
-				"exclude",
-				"strict",
-				// Temporarily exclude system.cpu.time (cpu/usage_time)
-				"system.cpu.time",
+			Processors: hostmetricsProcessors(r.ExtraMetrics),
+		})
+	}
+	return pipelines
+}
+
+// hostmetricsExtraMetrics are the metrics that are excluded unless extra_metrics is set.
+var hostmetricsExtraMetrics = []string{
+	"system.network.dropped",
+	"system.filesystem.inodes.usage",
+	"system.paging.faults",
+	"system.disk.operation_time",
+	"system.processes.count",
+}
+
+// hostmetricsProcessors returns the processors that translate the hostmetrics receiver's metrics into agent metrics.
+func hostmetricsProcessors(extraMetrics bool) []otel.Component {
+	excluded := []string{
+		// Temporarily exclude system.cpu.time (cpu/usage_time)
+		"system.cpu.time",
+	}
+	var extraTransforms []map[string]interface{}
+	if extraMetrics {
+		extraTransforms = []map[string]interface{}{
+			otel.RenameMetric(
+				"system.processes.count",
+				"processes/count_by_state",
+				otel.RenameLabel("status", "state"),
+			),
+			otel.RenameMetric(
 				"system.network.dropped",
+				"interface/dropped",
+				otel.RenameLabel("interface", "device"),
+				otel.RenameLabelValues("direction", map[string]string{
+					"receive":  "rx",
+					"transmit": "tx",
+				}),
+			),
+			otel.RenameMetric(
 				"system.filesystem.inodes.usage",
+				"disk/inodes_used",
+				// take sum over mode, mountpoint & type dimensions, retaining only device & state
+				otel.AggregateLabels("sum", "device", "state"),
+			),
+			otel.RenameMetric(
 				"system.paging.faults",
+				"swap/page_faults",
+			),
+			otel.RenameMetric(
 				"system.disk.operation_time",
-				"system.processes.count",
+				"disk/total_operation_time",
+				// convert s to ms
+				otel.ScaleValue(1000),
+				// change data type from double -> int64
+				otel.ToggleScalarDataType,
 			),
-			otel.MetricsTransform(
-				otel.RenameMetric(
-					"system.cpu.time",
-					"cpu/usage_time",
-					// change data type from double -> int64
-					otel.ToggleScalarDataType,
-					otel.RenameLabel("cpu", "cpu_number"),
-					otel.RenameLabel("state", "cpu_state"),
-				),
-				otel.RenameMetric(
-					"system.cpu.utilization",
-					"cpu/utilization",
-					// take avg over cpu dimension, retaining only state label
-					otel.AggregateLabels(
-						"mean",
-						"state",
-						"blank",
-					),
-					// add blank cpu_number label
-					otel.RenameLabel("blank", "cpu_number"),
-					// change label state -> cpu_state
-					otel.RenameLabel("state", "cpu_state"),
-				),
-				otel.RenameMetric(
-					"system.cpu.load_average.1m",
-					"cpu/load_1m",
-				),
-				otel.RenameMetric(
-					"system.cpu.load_average.5m",
-					"cpu/load_5m",
-				),
-				otel.RenameMetric(
-					"system.cpu.load_average.15m",
-					"cpu/load_15m",
-				),
-				otel.RenameMetric(
-					"system.disk.read_io", // as named after custom split logic
-					"disk/read_bytes_count",
-				),
-				otel.RenameMetric(
-					"system.disk.write_io", // as named after custom split logic
-					"disk/write_bytes_count",
-				),
-				otel.RenameMetric(
-					"system.disk.operations",
-					"disk/operation_count",
-				),
-				otel.RenameMetric(
-					"system.disk.io_time",
-					"disk/io_time",
-					// convert s to ms
-					otel.ScaleValue(1000),
-					// change data type from double -> int64
-					otel.ToggleScalarDataType,
-				),
-				otel.RenameMetric(
-					"system.disk.weighted_io_time",
-					"disk/weighted_io_time",
-					// convert s to ms
-					otel.ScaleValue(1000),
-					// change data type from double -> int64
-					otel.ToggleScalarDataType,
-				),
-				otel.RenameMetric(
-					"system.disk.average_operation_time",
-					"disk/operation_time",
-					// convert s to ms
-					otel.ScaleValue(1000),
-					// change data type from double -> int64
-					otel.ToggleScalarDataType,
-				),
-				otel.RenameMetric(
-					"system.disk.pending_operations",
-					"disk/pending_operations",
-					// change data type from int64 -> double
-					otel.ToggleScalarDataType,
-				),
-				otel.RenameMetric(
-					"system.disk.merged",
-					"disk/merged_operations",
-				),
-				otel.RenameMetric(
-					"system.filesystem.usage",
-					"disk/bytes_used",
-					// change data type from int64 -> double
-					otel.ToggleScalarDataType,
-					// take sum over mode, mountpoint & type dimensions, retaining only device & state
-					otel.AggregateLabels("sum", "device", "state"),
-				),
-				otel.RenameMetric(
-					"system.filesystem.utilization",
-					"disk/percent_used",
-					otel.AggregateLabels("sum", "device", "state"),
-				),
-				otel.RenameMetric(
-					"system.memory.usage",
-					"memory/bytes_used",
-					// change data type from int64 -> double
-					otel.ToggleScalarDataType,
-					// aggregate state label values: slab_reclaimable & slab_unreclaimable -> slab (note this is not currently supported)
-					otel.AggregateLabelValues("sum", "state", "slab", "slab_reclaimable", "slab_unreclaimable"),
-				),
-				otel.RenameMetric(
-					"system.memory.utilization",
-					"memory/percent_used",
-					// sum state label values: slab = slab_reclaimable + slab_unreclaimable
-					otel.AggregateLabelValues("sum", "state", "slab", "slab_reclaimable", "slab_unreclaimable"),
-				),
-				otel.RenameMetric(
-					"system.network.io",
-					"interface/traffic",
-					otel.RenameLabel("interface", "device"),
-					otel.RenameLabelValues("direction", map[string]string{
-						"receive":  "rx",
-						"transmit": "tx",
-					}),
-				),
-				otel.RenameMetric(
-					"system.network.errors",
-					"interface/errors",
-					otel.RenameLabel("interface", "device"),
-					otel.RenameLabelValues("direction", map[string]string{
-						"receive":  "rx",
-						"transmit": "tx",
-					}),
-				),
-				otel.RenameMetric(
-					"system.network.packets",
-					"interface/packets",
-					otel.RenameLabel("interface", "device"),
-					otel.RenameLabelValues("direction", map[string]string{
-						"receive":  "rx",
-						"transmit": "tx",
-					}),
-				),
-				otel.RenameMetric(
-					"system.network.connections",
-					"network/tcp_connections",
-					// change data type from int64 -> double
-					otel.ToggleScalarDataType,
-					// remove udp data
-					otel.DeleteLabelValue("protocol", "udp"),
-					otel.RenameLabel("state", "tcp_state"),
-					// remove protocol label
-					otel.AggregateLabels("sum", "tcp_state"),
-					otel.AddLabel("port", "all"),
-				),
-				otel.RenameMetric(
-					"system.processes.created",
-					"processes/fork_count",
-				),
-				otel.RenameMetric(
-					"system.paging.usage",
-					"swap/bytes_used",
-					// change data type from int64 -> double
-					otel.ToggleScalarDataType,
-				),
-				otel.RenameMetric(
-					"system.paging.utilization",
-					"swap/percent_used",
-				),
-				// duplicate swap/percent_used -> pagefile/percent_used
-				otel.DuplicateMetric(
-					"swap/percent_used",
-					"pagefile/percent_used",
-					// take sum over device dimension, retaining only state
-					otel.AggregateLabels("sum", "state"),
-				),
-				otel.RenameMetric(
-					"system.paging.operations",
-					"swap/io",
-					// delete single-valued type dimension, retaining only direction
-					otel.AggregateLabels("sum", "direction"),
-					otel.RenameLabelValues("direction", map[string]string{
-						"page_in":  "in",
-						"page_out": "out",
-					}),
-				),
-				otel.RenameMetric(
-					"process.cpu.time",
-					"processes/cpu_time",
-					// scale from seconds to microseconds
-					otel.ScaleValue(1000000),
-					// change data type from double -> int64
-					otel.ToggleScalarDataType,
-					otel.AddLabel("process", "all"),
-					// retain only user and syst state label values
-					otel.DeleteLabelValue("state", "wait"),
-					otel.RenameLabel("state", "user_or_syst"),
-					otel.RenameLabelValues("user_or_syst", map[string]string{
-						"system": "syst",
-					}),
-				),
-				otel.RenameMetric(
-					"process.disk.read_io", // as named after custom split logic
-					"processes/disk/read_bytes_count",
-					otel.AddLabel("process", "all"),
-				),
-				otel.RenameMetric(
-					"process.disk.write_io", // as named after custom split logic
-					"processes/disk/write_bytes_count",
-					otel.AddLabel("process", "all"),
-				),
-				otel.RenameMetric(
-					"process.memory.physical_usage",
-					"processes/rss_usage",
-					// change data type from int64 -> double
-					otel.ToggleScalarDataType,
-					otel.AddLabel("process", "all"),
-				),
-				otel.RenameMetric(
-					"process.memory.virtual_usage",
-					"processes/vm_usage",
-					// change data type from int64 -> double
-					otel.ToggleScalarDataType,
-					otel.AddLabel("process", "all"),
-				),
-				otel.AddPrefix("agent.googleapis.com"),
+		}
+	} else {
+		excluded = append(excluded, hostmetricsExtraMetrics...)
+	}
+	transforms := []map[string]interface{}{
+		otel.RenameMetric(
+			"system.cpu.time",
+			"cpu/usage_time",
+			// change data type from double -> int64
+			otel.ToggleScalarDataType,
+			otel.RenameLabel("cpu", "cpu_number"),
+			otel.RenameLabel("state", "cpu_state"),
+		),
+		otel.RenameMetric(
+			"system.cpu.utilization",
+			"cpu/utilization",
+			// take avg over cpu dimension, retaining only state label
+			otel.AggregateLabels(
+				"mean",
+				"state",
+				"blank",
 			),
+			// add blank cpu_number label
+			otel.RenameLabel("blank", "cpu_number"),
+			// change label state -> cpu_state
+			otel.RenameLabel("state", "cpu_state"),
+		),
+		otel.RenameMetric(
+			"system.cpu.load_average.1m",
+			"cpu/load_1m",
+		),
+		otel.RenameMetric(
+			"system.cpu.load_average.5m",
+			"cpu/load_5m",
+		),
+		otel.RenameMetric(
+			"system.cpu.load_average.15m",
+			"cpu/load_15m",
+		),
+		otel.RenameMetric(
+			"system.disk.read_io", // as named after custom split logic
+			"disk/read_bytes_count",
+		),
+		otel.RenameMetric(
+			"system.disk.write_io", // as named after custom split logic
+			"disk/write_bytes_count",
+		),
+		otel.RenameMetric(
+			"system.disk.operations",
+			"disk/operation_count",
+		),
+		otel.RenameMetric(
+			"system.disk.io_time",
+			"disk/io_time",
+			// convert s to ms
+			otel.ScaleValue(1000),
+			// change data type from double -> int64
+			otel.ToggleScalarDataType,
+		),
+		otel.RenameMetric(
+			"system.disk.weighted_io_time",
+			"disk/weighted_io_time",
+			// convert s to ms
+			otel.ScaleValue(1000),
+			// change data type from double -> int64
+			otel.ToggleScalarDataType,
+		),
+		otel.RenameMetric(
+			"system.disk.average_operation_time",
+			"disk/operation_time",
+			// convert s to ms
+			otel.ScaleValue(1000),
+			// change data type from double -> int64
+			otel.ToggleScalarDataType,
+		),
+		otel.RenameMetric(
+			"system.disk.pending_operations",
+			"disk/pending_operations",
+			// change data type from int64 -> double
+			otel.ToggleScalarDataType,
+		),
+		otel.RenameMetric(
+			"system.disk.merged",
+			"disk/merged_operations",
+		),
+		otel.RenameMetric(
+			"system.filesystem.usage",
+			"disk/bytes_used",
+			// change data type from int64 -> double
+			otel.ToggleScalarDataType,
+			// take sum over mode, mountpoint & type dimensions, retaining only device & state
+			otel.AggregateLabels("sum", "device", "state"),
+		),
+		otel.RenameMetric(
+			"system.filesystem.utilization",
+			"disk/percent_used",
+			otel.AggregateLabels("sum", "device", "state"),
+		),
+		otel.RenameMetric(
+			"system.memory.usage",
+			"memory/bytes_used",
+			// change data type from int64 -> double
+			otel.ToggleScalarDataType,
+			// aggregate state label values: slab_reclaimable & slab_unreclaimable -> slab (note this is not currently supported)
+			otel.AggregateLabelValues("sum", "state", "slab", "slab_reclaimable", "slab_unreclaimable"),
+		),
+		otel.RenameMetric(
+			"system.memory.utilization",
+			"memory/percent_used",
+			// sum state label values: slab = slab_reclaimable + slab_unreclaimable
+			otel.AggregateLabelValues("sum", "state", "slab", "slab_reclaimable", "slab_unreclaimable"),
+		),
+		otel.RenameMetric(
+			"system.network.io",
+			"interface/traffic",
+			otel.RenameLabel("interface", "device"),
+			otel.RenameLabelValues("direction", map[string]string{
+				"receive":  "rx",
+				"transmit": "tx",
+			}),
+		),
+		otel.RenameMetric(
+			"system.network.errors",
+			"interface/errors",
+			otel.RenameLabel("interface", "device"),
+			otel.RenameLabelValues("direction", map[string]string{
+				"receive":  "rx",
+				"transmit": "tx",
+			}),
+		),
+		otel.RenameMetric(
+			"system.network.packets",
+			"interface/packets",
+			otel.RenameLabel("interface", "device"),
+			otel.RenameLabelValues("direction", map[string]string{
+				"receive":  "rx",
+				"transmit": "tx",
+			}),
+		),
+		otel.RenameMetric(
+			"system.network.connections",
+			"network/tcp_connections",
+			// change data type from int64 -> double
+			otel.ToggleScalarDataType,
+			// remove udp data
+			otel.DeleteLabelValue("protocol", "udp"),
+			otel.RenameLabel("state", "tcp_state"),
+			// remove protocol label
+			otel.AggregateLabels("sum", "tcp_state"),
+			otel.AddLabel("port", "all"),
+		),
+		otel.RenameMetric(
+			"system.processes.created",
+			"processes/fork_count",
+		),
+		otel.RenameMetric(
+			"system.paging.usage",
+			"swap/bytes_used",
+			// change data type from int64 -> double
+			otel.ToggleScalarDataType,
+		),
+		otel.RenameMetric(
+			"system.paging.utilization",
+			"swap/percent_used",
+		),
+		// duplicate swap/percent_used -> pagefile/percent_used
+		otel.DuplicateMetric(
+			"swap/percent_used",
+			"pagefile/percent_used",
+			// take sum over device dimension, retaining only state
+			otel.AggregateLabels("sum", "state"),
+		),
+		otel.RenameMetric(
+			"system.paging.operations",
+			"swap/io",
+			// delete single-valued type dimension, retaining only direction
+			otel.AggregateLabels("sum", "direction"),
+			otel.RenameLabelValues("direction", map[string]string{
+				"page_in":  "in",
+				"page_out": "out",
+			}),
+		),
+		otel.RenameMetric(
+			"process.cpu.time",
+			"processes/cpu_time",
+			// scale from seconds to microseconds
+			otel.ScaleValue(1000000),
+			// change data type from double -> int64
+			otel.ToggleScalarDataType,
+			otel.AddLabel("process", "all"),
+			// retain only user and syst state label values
+			otel.DeleteLabelValue("state", "wait"),
+			otel.RenameLabel("state", "user_or_syst"),
+			otel.RenameLabelValues("user_or_syst", map[string]string{
+				"system": "syst",
+			}),
+		),
+		otel.RenameMetric(
+			"process.disk.read_io", // as named after custom split logic
+			"processes/disk/read_bytes_count",
+			otel.AddLabel("process", "all"),
+		),
+		otel.RenameMetric(
+			"process.disk.write_io", // as named after custom split logic
+			"processes/disk/write_bytes_count",
+			otel.AddLabel("process", "all"),
+		),
+		otel.RenameMetric(
+			"process.memory.physical_usage",
+			"processes/rss_usage",
+			// change data type from int64 -> double
+			otel.ToggleScalarDataType,
+			otel.AddLabel("process", "all"),
+		),
+		otel.RenameMetric(
+			"process.memory.virtual_usage",
+			"processes/vm_usage",
+			// change data type from int64 -> double
+			otel.ToggleScalarDataType,
+			otel.AddLabel("process", "all"),
+		),
+	}
+	transforms = append(transforms, extraTransforms...)
+	transforms = append(transforms, otel.AddPrefix("agent.googleapis.com"))
+	return []otel.Component{
+		{
+			// perform custom transformations that aren't supported by the metricstransform processor
+			Type: "agentmetrics",
+			Config: map[string]interface{}{
+				// https://github.com/GoogleCloudPlatform/opentelemetry-operations-collector/blob/master/processor/agentmetricsprocessor/agentmetricsprocessor.go#L58
+				"blank_label_metrics": []string{
+					"system.cpu.utilization",
+				},
+			},
 		},
-	}}
+		otel.MetricsFilter(
+			"exclude",
+			"strict",
+			excluded...,
+		),
+		otel.MetricsTransform(transforms...),
+	}
 }
 
 func init() {
@@ -19,9 +19,11 @@ import (
 	"log"
 	"path/filepath"
 	"runtime"
+	"strconv"
 
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator"
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/otel"
+	"github.com/GoogleCloudPlatform/ops-agent/discovery"
 	"github.com/kardianos/osext"
 )
 
@@ -98,3 +100,37 @@ var FindJarPath = func() (string, error) {
 func init() {
 	confgenerator.MetricsReceiverTypes.RegisterType(func() confgenerator.Component { return &MetricsReceiverJVM{} })
 }
+
+func init() {
+	// Java processes are only recognized as a generic JVM when no other rule claimed them, and when they
+	// have remote JMX enabled.
+	discovery.Rules.Register(discovery.Rule{
+		Name: "jvm",
+		Match: func(p discovery.Process) bool {
+			_, ok := jmxPort(p, "-Dcom.sun.management.jmxremote.port=")
+			return p.Name == "java" && ok
+		},
+		Fallback:     true,
+		MetricsGroup: "jvm",
+		MetricsReceiver: func(d discovery.Detection) confgenerator.MetricsReceiver {
+			port, _ := jmxPort(d.Process, "-Dcom.sun.management.jmxremote.port=")
+			return &MetricsReceiverJVM{
+				ConfigComponent:       confgenerator.ConfigComponent{Type: "jvm"},
+				MetricsReceiverShared: confgenerator.MetricsReceiverShared{CollectionInterval: "60s"},
+				Endpoint:              fmt.Sprintf("localhost:%d", port),
+			}
+		},
+	})
+}
+
+// jmxPort returns the JMX port set by the first of the given system property flags (e.g. "-Dcom.sun.management.jmxremote.port=") on the command line of a Java process.
+func jmxPort(p discovery.Process, flags ...string) (int, bool) {
+	for _, flag := range flags {
+		if v, ok := p.Arg(flag); ok {
+			if port, err := strconv.Atoi(v); err == nil {
+				return port, true
+			}
+		}
+	}
+	return 0, false
+}
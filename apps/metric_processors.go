@@ -17,16 +17,21 @@ package apps
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator"
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/otel"
 )
 
+// MetricsProcessorExcludeMetrics drops the metrics matching metrics_pattern.
+// Patterns are matched against the full metric names, e.g. "workload.googleapis.com/redis.clients.connected".
 type MetricsProcessorExcludeMetrics struct {
 	confgenerator.ConfigComponent `yaml:",inline"`
 
-	MetricsPattern []string `yaml:"metrics_pattern,flow" validate:"dive,endswith=/*,startswith=agent.googleapis.com/"`
+	MetricsPattern []string `yaml:"metrics_pattern,flow" validate:"dive,required,regexp_if=MatchType regexp"`
+	// MatchType is "glob" (the default), where "*" matches any sequence of characters, or "regexp".
+	MatchType string `yaml:"match_type,omitempty" validate:"omitempty,oneof=glob regexp"`
 }
 
 func (p MetricsProcessorExcludeMetrics) Type() string {
@@ -34,23 +39,229 @@ func (p MetricsProcessorExcludeMetrics) Type() string {
 }
 
 func (p MetricsProcessorExcludeMetrics) Processors() []otel.Component {
-	var metricNames []string
-	for _, glob := range p.MetricsPattern {
-		// TODO: Remove TrimPrefix when we support metrics with other prefixes.
-		glob = strings.TrimPrefix(glob, "agent.googleapis.com/")
-		var literals []string
-		for _, g := range strings.Split(glob, "*") {
-			literals = append(literals, regexp.QuoteMeta(g))
-		}
-		metricNames = append(metricNames, fmt.Sprintf(`^%s$`, strings.Join(literals, `.*`)))
-	}
 	return []otel.Component{otel.MetricsFilter(
 		"exclude",
 		"regexp",
-		metricNames...,
+		metricsPatternRegexps(p.MetricsPattern, p.MatchType)...,
 	)}
 }
 
 func init() {
 	confgenerator.MetricsProcessorTypes.RegisterType(func() confgenerator.Component { return &MetricsProcessorExcludeMetrics{} })
 }
+
+// globToRegexp converts a glob, where "*" matches any sequence of characters, to an unanchored regexp.
+func globToRegexp(glob string) string {
+	var literals []string
+	for _, g := range strings.Split(glob, "*") {
+		literals = append(literals, regexp.QuoteMeta(g))
+	}
+	return strings.Join(literals, `.*`)
+}
+
+// metricsPatternRegexps returns one anchored regexp per pattern. matchType is "glob" or "regexp"; regexps are returned as is.
+func metricsPatternRegexps(patterns []string, matchType string) []string {
+	var out []string
+	for _, pattern := range patterns {
+		if matchType == "regexp" {
+			out = append(out, pattern)
+			continue
+		}
+		out = append(out, fmt.Sprintf(`^%s$`, globToRegexp(pattern)))
+	}
+	return out
+}
+
+// metricsPatternRegexp returns a regexp matching the full names of the metrics that match any of the globs, or all metrics if there are none.
+func metricsPatternRegexp(globs []string) string {
+	if len(globs) == 0 {
+		return `^.*$`
+	}
+	var alternatives []string
+	for _, glob := range globs {
+		alternatives = append(alternatives, globToRegexp(glob))
+	}
+	return fmt.Sprintf(`^(?:%s)$`, strings.Join(alternatives, "|"))
+}
+
+// MetricsProcessorIncludeMetrics drops all metrics except the ones matching metrics_pattern.
+type MetricsProcessorIncludeMetrics struct {
+	confgenerator.ConfigComponent `yaml:",inline"`
+
+	MetricsPattern []string `yaml:"metrics_pattern,flow" validate:"required,dive,required,regexp_if=MatchType regexp"`
+	// MatchType is "glob" (the default), where "*" matches any sequence of characters, or "regexp".
+	MatchType string `yaml:"match_type,omitempty" validate:"omitempty,oneof=glob regexp"`
+}
+
+func (p MetricsProcessorIncludeMetrics) Type() string {
+	return "include_metrics"
+}
+
+func (p MetricsProcessorIncludeMetrics) Processors() []otel.Component {
+	return []otel.Component{otel.MetricsFilter(
+		"include",
+		"regexp",
+		metricsPatternRegexps(p.MetricsPattern, p.MatchType)...,
+	)}
+}
+
+func init() {
+	confgenerator.MetricsProcessorTypes.RegisterType(func() confgenerator.Component { return &MetricsProcessorIncludeMetrics{} })
+}
+
+// MetricsProcessorRenameMetrics renames metrics, from the full old name to the full new name.
+type MetricsProcessorRenameMetrics struct {
+	confgenerator.ConfigComponent `yaml:",inline"`
+
+	Metrics map[string]string `yaml:"metrics" validate:"required,dive,keys,required,endkeys,required"`
+}
+
+func (p MetricsProcessorRenameMetrics) Type() string {
+	return "rename_metrics"
+}
+
+func (p MetricsProcessorRenameMetrics) Processors() []otel.Component {
+	var olds []string
+	for old := range p.Metrics {
+		olds = append(olds, old)
+	}
+	sort.Strings(olds)
+	var transforms []map[string]interface{}
+	for _, old := range olds {
+		transforms = append(transforms, otel.RenameMetric(old, p.Metrics[old]))
+	}
+	return []otel.Component{otel.MetricsTransform(transforms...)}
+}
+
+func init() {
+	confgenerator.MetricsProcessorTypes.RegisterType(func() confgenerator.Component { return &MetricsProcessorRenameMetrics{} })
+}
+
+// MetricsProcessorAddLabels adds labels with fixed values to the metrics matching metrics_pattern.
+type MetricsProcessorAddLabels struct {
+	confgenerator.ConfigComponent `yaml:",inline"`
+
+	MetricsPattern []string          `yaml:"metrics_pattern,omitempty,flow"`
+	Labels         map[string]string `yaml:"labels" validate:"required,dive,keys,required,endkeys"`
+}
+
+func (p MetricsProcessorAddLabels) Type() string {
+	return "add_labels"
+}
+
+func (p MetricsProcessorAddLabels) Processors() []otel.Component {
+	var keys []string
+	for k := range p.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var operations []map[string]interface{}
+	for _, k := range keys {
+		operations = append(operations, otel.AddLabel(k, p.Labels[k]))
+	}
+	return []otel.Component{otel.MetricsTransform(
+		otel.UpdateMetricsRegexp(metricsPatternRegexp(p.MetricsPattern), operations...),
+	)}
+}
+
+func init() {
+	confgenerator.MetricsProcessorTypes.RegisterType(func() confgenerator.Component { return &MetricsProcessorAddLabels{} })
+}
+
+// MetricsProcessorDropLabelValues drops whole time series: those of the metrics matching metrics_pattern whose labels have one of the given values.
+// The label keys themselves are kept on the remaining time series; use aggregate_labels to remove label keys.
+type MetricsProcessorDropLabelValues struct {
+	confgenerator.ConfigComponent `yaml:",inline"`
+
+	MetricsPattern []string            `yaml:"metrics_pattern,omitempty,flow"`
+	LabelValues    map[string][]string `yaml:"label_values" validate:"required,dive,keys,required,endkeys,required"`
+}
+
+func (p MetricsProcessorDropLabelValues) Type() string {
+	return "drop_label_values"
+}
+
+func (p MetricsProcessorDropLabelValues) Processors() []otel.Component {
+	var labels []string
+	for l := range p.LabelValues {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+	var operations []map[string]interface{}
+	for _, l := range labels {
+		for _, v := range p.LabelValues[l] {
+			operations = append(operations, otel.DeleteLabelValue(l, v))
+		}
+	}
+	return []otel.Component{otel.MetricsTransform(
+		otel.UpdateMetricsRegexp(metricsPatternRegexp(p.MetricsPattern), operations...),
+	)}
+}
+
+func init() {
+	confgenerator.MetricsProcessorTypes.RegisterType(func() confgenerator.Component { return &MetricsProcessorDropLabelValues{} })
+}
+
+// MetricsProcessorRenameLabels renames label keys of the metrics matching metrics_pattern, from the old key to the new key.
+type MetricsProcessorRenameLabels struct {
+	confgenerator.ConfigComponent `yaml:",inline"`
+
+	MetricsPattern []string          `yaml:"metrics_pattern,omitempty,flow"`
+	Labels         map[string]string `yaml:"labels" validate:"required,dive,keys,required,endkeys,required"`
+}
+
+func (p MetricsProcessorRenameLabels) Type() string {
+	return "rename_labels"
+}
+
+func (p MetricsProcessorRenameLabels) Processors() []otel.Component {
+	var olds []string
+	for old := range p.Labels {
+		olds = append(olds, old)
+	}
+	sort.Strings(olds)
+	var operations []map[string]interface{}
+	for _, old := range olds {
+		operations = append(operations, otel.RenameLabel(old, p.Labels[old]))
+	}
+	return []otel.Component{otel.MetricsTransform(
+		otel.UpdateMetricsRegexp(metricsPatternRegexp(p.MetricsPattern), operations...),
+	)}
+}
+
+func init() {
+	confgenerator.MetricsProcessorTypes.RegisterType(func() confgenerator.Component { return &MetricsProcessorRenameLabels{} })
+}
+
+// MetricsProcessorAggregateLabels removes all labels except the given ones from the metrics matching metrics_pattern,
+// aggregating the values of the time series that become identical.
+type MetricsProcessorAggregateLabels struct {
+	confgenerator.ConfigComponent `yaml:",inline"`
+
+	MetricsPattern  []string `yaml:"metrics_pattern,omitempty,flow"`
+	Labels          []string `yaml:"labels,flow"`
+	AggregationType string   `yaml:"aggregation_type,omitempty" validate:"omitempty,oneof=sum mean min max"`
+}
+
+func (p MetricsProcessorAggregateLabels) Type() string {
+	return "aggregate_labels"
+}
+
+func (p MetricsProcessorAggregateLabels) Processors() []otel.Component {
+	aggregationType := p.AggregationType
+	if aggregationType == "" {
+		aggregationType = "sum"
+	}
+	labels := p.Labels
+	if labels == nil {
+		// Aggregate away all the labels.
+		labels = []string{}
+	}
+	return []otel.Component{otel.MetricsTransform(
+		otel.UpdateMetricsRegexp(metricsPatternRegexp(p.MetricsPattern), otel.AggregateLabels(aggregationType, labels...)),
+	)}
+}
+
+func init() {
+	confgenerator.MetricsProcessorTypes.RegisterType(func() confgenerator.Component { return &MetricsProcessorAggregateLabels{} })
+}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator"
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/fluentbit"
+	"github.com/GoogleCloudPlatform/ops-agent/discovery"
 )
 
 type LoggingProcessorMysqlError struct {
@@ -237,55 +238,64 @@ func (p LoggingProcessorMysqlSlow) Components(tag string, uid string) []fluentbi
 	return c
 }
 
+// mysqlGeneralLogPaths are the default log paths of the mysql_general receiver.
+var mysqlGeneralLogPaths = []string{
+	// Default log path for CentOS / RHEL / SLES / Debain / Ubuntu
+	"/var/lib/mysql/${HOSTNAME}.log",
+}
+
 type LoggingReceiverMysqlGeneral struct {
 	LoggingProcessorMysqlGeneral            `yaml:",inline"`
-	confgenerator.LoggingReceiverFilesMixin `yaml:",inline" validate:"structonly"`
+	confgenerator.LoggingReceiverFilesMixin `yaml:",inline"`
 }
 
 func (r LoggingReceiverMysqlGeneral) Components(tag string) []fluentbit.Component {
 	if len(r.IncludePaths) == 0 {
-		r.IncludePaths = []string{
-			// Default log path for CentOS / RHEL / SLES / Debain / Ubuntu
-			"/var/lib/mysql/${HOSTNAME}.log",
-		}
+		r.IncludePaths = mysqlGeneralLogPaths
 	}
 	c := r.LoggingReceiverFilesMixin.Components(tag)
 	c = append(c, r.LoggingProcessorMysqlGeneral.Components(tag, "mysql_general")...)
 	return c
 }
 
+// mysqlSlowLogPaths are the default log paths of the mysql_slow receiver.
+var mysqlSlowLogPaths = []string{
+	// Default log path for CentOS / RHEL / SLES / Debain / Ubuntu
+	"/var/lib/mysql/${HOSTNAME}-slow.log",
+}
+
 type LoggingReceiverMysqlSlow struct {
 	LoggingProcessorMysqlSlow               `yaml:",inline"`
-	confgenerator.LoggingReceiverFilesMixin `yaml:",inline" validate:"structonly"`
+	confgenerator.LoggingReceiverFilesMixin `yaml:",inline"`
 }
 
 func (r LoggingReceiverMysqlSlow) Components(tag string) []fluentbit.Component {
 	if len(r.IncludePaths) == 0 {
-		r.IncludePaths = []string{
-			// Default log path for CentOS / RHEL / SLES / Debain / Ubuntu
-			"/var/lib/mysql/${HOSTNAME}-slow.log",
-		}
+		r.IncludePaths = mysqlSlowLogPaths
 	}
 	c := r.LoggingReceiverFilesMixin.Components(tag)
 	c = append(c, r.LoggingProcessorMysqlSlow.Components(tag, "mysql_slow")...)
 	return c
 }
 
+// mysqlErrorLogPaths are the default log paths of the mysql_error receiver.
+var mysqlErrorLogPaths = []string{
+	// Default log path for CentOS / RHEL
+	"/var/log/mysqld.log",
+	// Default log path for SLES
+	"/var/log/mysql/mysqld.log",
+	// Default log path for Debian / Ubuntu
+	"/var/log/mysql/error.log",
+}
+
 type LoggingReceiverMysqlError struct {
 	LoggingProcessorMysqlError              `yaml:",inline"`
-	confgenerator.LoggingReceiverFilesMixin `yaml:",inline" validate:"structonly"`
+	confgenerator.LoggingReceiverFilesMixin `yaml:",inline"`
 }
 
 func (r LoggingReceiverMysqlError) Components(tag string) []fluentbit.Component {
 	if len(r.IncludePaths) == 0 {
-		r.IncludePaths = []string{
-			// Default log path for CentOS / RHEL
-			"/var/log/mysqld.log",
-			// Default log path for SLES
-			"/var/log/mysql/mysqld.log",
-			// Default log path for Debian / Ubuntu
-			"/var/log/mysql/error.log",
-		}
+		r.IncludePaths = mysqlErrorLogPaths
 	}
 	c := r.LoggingReceiverFilesMixin.Components(tag)
 	c = append(c, r.LoggingProcessorMysqlError.Components(tag, "mysql_error")...)
@@ -300,3 +310,45 @@ func init() {
 	confgenerator.LoggingReceiverTypes.RegisterType(func() confgenerator.Component { return &LoggingReceiverMysqlGeneral{} })
 	confgenerator.LoggingReceiverTypes.RegisterType(func() confgenerator.Component { return &LoggingReceiverMysqlSlow{} })
 }
+
+func init() {
+	// There is no mysql metrics receiver yet, so only logs are discovered.
+	discovery.Rules.Register(discovery.Rule{
+		Name: "mysql",
+		Match: func(p discovery.Process) bool {
+			return p.Name == "mysqld" || p.Name == "mariadbd"
+		},
+		Logs: []discovery.LogSource{
+			{
+				Type:  "mysql_error",
+				Paths: mysqlErrorLogPaths,
+				Receiver: func(includePaths []string) confgenerator.LoggingReceiver {
+					return &LoggingReceiverMysqlError{
+						LoggingProcessorMysqlError: LoggingProcessorMysqlError{ConfigComponent: confgenerator.ConfigComponent{Type: "mysql_error"}},
+						LoggingReceiverFilesMixin:  confgenerator.LoggingReceiverFilesMixin{IncludePaths: includePaths},
+					}
+				},
+			},
+			{
+				Type:  "mysql_general",
+				Paths: mysqlGeneralLogPaths,
+				Receiver: func(includePaths []string) confgenerator.LoggingReceiver {
+					return &LoggingReceiverMysqlGeneral{
+						LoggingProcessorMysqlGeneral: LoggingProcessorMysqlGeneral{ConfigComponent: confgenerator.ConfigComponent{Type: "mysql_general"}},
+						LoggingReceiverFilesMixin:    confgenerator.LoggingReceiverFilesMixin{IncludePaths: includePaths},
+					}
+				},
+			},
+			{
+				Type:  "mysql_slow",
+				Paths: mysqlSlowLogPaths,
+				Receiver: func(includePaths []string) confgenerator.LoggingReceiver {
+					return &LoggingReceiverMysqlSlow{
+						LoggingProcessorMysqlSlow: LoggingProcessorMysqlSlow{ConfigComponent: confgenerator.ConfigComponent{Type: "mysql_slow"}},
+						LoggingReceiverFilesMixin: confgenerator.LoggingReceiverFilesMixin{IncludePaths: includePaths},
+					}
+				},
+			},
+		},
+	})
+}
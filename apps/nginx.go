@@ -20,6 +20,7 @@ import (
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator"
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/fluentbit"
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/otel"
+	"github.com/GoogleCloudPlatform/ops-agent/discovery"
 )
 
 type MetricsReceiverNginx struct {
@@ -159,28 +160,34 @@ func (p LoggingProcessorNginxError) Components(tag string, uid string) []fluentb
 	return c
 }
 
+// nginxAccessLogPaths are the default log paths of the nginx_access receiver.
+var nginxAccessLogPaths = []string{"/var/log/nginx/access.log"}
+
 type LoggingReceiverNginxAccess struct {
 	LoggingProcessorNginxAccess             `yaml:",inline"`
-	confgenerator.LoggingReceiverFilesMixin `yaml:",inline" validate:"structonly"`
+	confgenerator.LoggingReceiverFilesMixin `yaml:",inline"`
 }
 
 func (r LoggingReceiverNginxAccess) Components(tag string) []fluentbit.Component {
 	if len(r.IncludePaths) == 0 {
-		r.IncludePaths = []string{"/var/log/nginx/access.log"}
+		r.IncludePaths = nginxAccessLogPaths
 	}
 	c := r.LoggingReceiverFilesMixin.Components(tag)
 	c = append(c, r.LoggingProcessorNginxAccess.Components(tag, "nginx_access")...)
 	return c
 }
 
+// nginxErrorLogPaths are the default log paths of the nginx_error receiver.
+var nginxErrorLogPaths = []string{"/var/log/nginx/error.log"}
+
 type LoggingReceiverNginxError struct {
 	LoggingProcessorNginxError              `yaml:",inline"`
-	confgenerator.LoggingReceiverFilesMixin `yaml:",inline" validate:"structonly"`
+	confgenerator.LoggingReceiverFilesMixin `yaml:",inline"`
 }
 
 func (r LoggingReceiverNginxError) Components(tag string) []fluentbit.Component {
 	if len(r.IncludePaths) == 0 {
-		r.IncludePaths = []string{"/var/log/nginx/error.log"}
+		r.IncludePaths = nginxErrorLogPaths
 	}
 	c := r.LoggingReceiverFilesMixin.Components(tag)
 	c = append(c, r.LoggingProcessorNginxError.Components(tag, "nginx_error")...)
@@ -193,3 +200,41 @@ func init() {
 	confgenerator.LoggingReceiverTypes.RegisterType(func() confgenerator.Component { return &LoggingReceiverNginxAccess{} })
 	confgenerator.LoggingReceiverTypes.RegisterType(func() confgenerator.Component { return &LoggingReceiverNginxError{} })
 }
+
+func init() {
+	discovery.Rules.Register(discovery.Rule{
+		Name: "nginx",
+		Match: func(p discovery.Process) bool {
+			return p.Name == "nginx"
+		},
+		MetricsReceiver: func(d discovery.Detection) confgenerator.MetricsReceiver {
+			return &MetricsReceiverNginx{
+				ConfigComponent:       confgenerator.ConfigComponent{Type: "nginx"},
+				MetricsReceiverShared: confgenerator.MetricsReceiverShared{CollectionInterval: "60s"},
+				StubStatusURL:         fmt.Sprintf("http://localhost:%d/status", d.Port(80)),
+			}
+		},
+		Logs: []discovery.LogSource{
+			{
+				Type:  "nginx_access",
+				Paths: nginxAccessLogPaths,
+				Receiver: func(includePaths []string) confgenerator.LoggingReceiver {
+					return &LoggingReceiverNginxAccess{
+						LoggingProcessorNginxAccess: LoggingProcessorNginxAccess{ConfigComponent: confgenerator.ConfigComponent{Type: "nginx_access"}},
+						LoggingReceiverFilesMixin:   confgenerator.LoggingReceiverFilesMixin{IncludePaths: includePaths},
+					}
+				},
+			},
+			{
+				Type:  "nginx_error",
+				Paths: nginxErrorLogPaths,
+				Receiver: func(includePaths []string) confgenerator.LoggingReceiver {
+					return &LoggingReceiverNginxError{
+						LoggingProcessorNginxError: LoggingProcessorNginxError{ConfigComponent: confgenerator.ConfigComponent{Type: "nginx_error"}},
+						LoggingReceiverFilesMixin:  confgenerator.LoggingReceiverFilesMixin{IncludePaths: includePaths},
+					}
+				},
+			},
+		},
+	})
+}
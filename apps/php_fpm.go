@@ -0,0 +1,172 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apps
+
+import (
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator"
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/fluentbit"
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/otel"
+)
+
+type MetricsReceiverPhpFpm struct {
+	confgenerator.ConfigComponent `yaml:",inline"`
+
+	confgenerator.MetricsReceiverShared `yaml:",inline"`
+
+	// The FastCGI socket can't be scraped directly, so the status page must be served over HTTP.
+	StatusURL string `yaml:"status_url" validate:"omitempty,http_url"`
+}
+
+// defaultPhpFpmStatusURL assumes pm.status_path = /status, served by the web server in front of FPM.
+const defaultPhpFpmStatusURL = "http://localhost/status"
+
+func (r MetricsReceiverPhpFpm) Type() string {
+	return "php_fpm"
+}
+
+func (r MetricsReceiverPhpFpm) Pipelines() []otel.Pipeline {
+	if r.StatusURL == "" {
+		r.StatusURL = defaultPhpFpmStatusURL
+	}
+	scrapeConfig := prometheusScrapeConfig("php_fpm", r.CollectionIntervalString(), r.StatusURL, confgenerator.MetricsReceiverSharedTLS{})
+	// Since PHP 8.1 the status page can be rendered in the OpenMetrics format, which the prometheus receiver understands.
+	// Older versions ignore the parameter and serve a page the receiver can't parse, so no metrics are collected.
+	params, _ := scrapeConfig["params"].(map[string][]string)
+	if params == nil {
+		params = map[string][]string{}
+	}
+	params["openmetrics"] = []string{""}
+	scrapeConfig["params"] = params
+
+	return []otel.Pipeline{{
+		Receiver: prometheusScrapeReceiver(scrapeConfig),
+		Processors: []otel.Component{
+			otel.MetricsFilter(
+				"include",
+				"strict",
+				"phpfpm_active_processes",
+				"phpfpm_idle_processes",
+				"phpfpm_accepted_connections",
+				"phpfpm_slow_requests",
+				"phpfpm_max_children_reached",
+			),
+			otel.NormalizeSums(),
+			otel.MetricsTransform(
+				otel.RenameMetric("phpfpm_active_processes", "php_fpm.processes.active"),
+				otel.RenameMetric("phpfpm_idle_processes", "php_fpm.processes.idle"),
+				otel.RenameMetric("phpfpm_accepted_connections", "php_fpm.connections.accepted"),
+				otel.RenameMetric("phpfpm_slow_requests", "php_fpm.requests.slow"),
+				otel.RenameMetric("phpfpm_max_children_reached", "php_fpm.max_children_reached"),
+				otel.AddPrefix("workload.googleapis.com"),
+			),
+		},
+	}}
+}
+
+func init() {
+	confgenerator.MetricsReceiverTypes.RegisterType(func() confgenerator.Component { return &MetricsReceiverPhpFpm{} })
+}
+
+type LoggingProcessorPhpFpm struct {
+	confgenerator.ConfigComponent `yaml:",inline"`
+}
+
+func (LoggingProcessorPhpFpm) Type() string {
+	return "php_fpm"
+}
+
+func (p LoggingProcessorPhpFpm) Components(tag string, uid string) []fluentbit.Component {
+	// FPM only logs up to the second by default; sub-second precision is dropped if present.
+	const timestamp = `^\[(?<time>\d{2}-\w{3}-\d{4}\s+\d{2}:\d{2}:\d{2})(?:\.\d+)?\]`
+	parser := confgenerator.ParserShared{
+		TimeKey:    "time",
+		TimeFormat: "%d-%b-%Y %H:%M:%S",
+		Types: map[string]string{
+			"pid": "integer",
+		},
+	}
+	c := confgenerator.LoggingProcessorParseMultilineRegex{
+		LoggingProcessorParseRegexComplex: confgenerator.LoggingProcessorParseRegexComplex{
+			Parsers: []confgenerator.RegexParser{
+				{
+					// Slowlog entries are a header followed by the PHP stack trace of the slow request.
+					// Sample entry: [30-Nov-2021 14:03:01]  [pool www] pid 1236
+					// 				script_filename = /var/www/html/index.php
+					// 				[0x00007f3c1b213f30] sleep() /var/www/html/index.php:3
+					Regex:  timestamp + `\s+\[pool (?<pool>[^\]]+)\] pid (?<pid>\d+)\n(?:script_filename = (?<script_filename>[^\n]*)\n)?(?<message>[\s\S]*)`,
+					Parser: parser,
+				},
+				{
+					// Documentation: https://www.php.net/manual/en/install.fpm.configuration.php#log-level
+					// Sample line: [30-Nov-2021 14:03:01] NOTICE: fpm is running, pid 1234
+					// Sample line: [30-Nov-2021 14:05:12] WARNING: [pool www] server reached pm.max_children setting (5), consider raising it
+					Regex:  timestamp + `\s+(?<level>[A-Z]+):\s+(?:\[pool (?<pool>[^\]]+)\]\s+)?(?<message>[\s\S]*)`,
+					Parser: parser,
+				},
+			},
+		},
+		Rules: []confgenerator.MultilineRule{
+			{
+				StateName: "start_state",
+				NextState: "cont",
+				Regex:     `^\[\d{2}-\w{3}-\d{4}\s+\d{2}:\d{2}:\d{2}`,
+			},
+			{
+				StateName: "cont",
+				NextState: "cont",
+				Regex:     `^(?!\[\d{2}-\w{3}-\d{4}\s+\d{2}:\d{2}:\d{2})`,
+			},
+		},
+	}.Components(tag, uid)
+
+	// Log levels documented: https://github.com/php/php-src/blob/master/sapi/fpm/fpm/zlog.c
+	c = append(c,
+		fluentbit.TranslationComponents(tag, "level", "logging.googleapis.com/severity",
+			[]struct{ SrcVal, DestVal string }{
+				{"DEBUG", "DEBUG"},
+				{"NOTICE", "NOTICE"},
+				{"WARNING", "WARNING"},
+				{"ERROR", "ERROR"},
+				{"ALERT", "ALERT"},
+			},
+		)...,
+	)
+	return c
+}
+
+type LoggingReceiverPhpFpm struct {
+	LoggingProcessorPhpFpm                  `yaml:",inline"`
+	confgenerator.LoggingReceiverFilesMixin `yaml:",inline"`
+}
+
+func (r LoggingReceiverPhpFpm) Components(tag string) []fluentbit.Component {
+	if len(r.IncludePaths) == 0 {
+		r.IncludePaths = []string{
+			// Default error log path on Debian / Ubuntu, e.g. /var/log/php7.4-fpm.log
+			"/var/log/php*-fpm.log",
+			// Default error log and slowlog paths on CentOS / RHEL
+			"/var/log/php-fpm/error.log",
+			"/var/log/php-fpm/*-slow.log",
+		}
+	}
+	c := r.LoggingReceiverFilesMixin.Components(tag)
+	c = append(c, r.LoggingProcessorPhpFpm.Components(tag, "php_fpm")...)
+	return c
+}
+
+func init() {
+	confgenerator.LoggingProcessorTypes.RegisterType(func() confgenerator.Component { return &LoggingProcessorPhpFpm{} })
+	confgenerator.LoggingReceiverTypes.RegisterType(func() confgenerator.Component { return &LoggingReceiverPhpFpm{} })
+}
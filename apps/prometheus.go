@@ -0,0 +1,73 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apps
+
+import (
+	"net/url"
+
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator"
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/otel"
+)
+
+// prometheusScrapeConfig returns a Prometheus scrape config for the single target at endpoint.
+// endpoint must already have been checked by the http_url validator.
+// "insecure" skips the verification of the server certificate, like "insecure_skip_verify", which takes precedence.
+func prometheusScrapeConfig(jobName, scrapeInterval, endpoint string, tls confgenerator.MetricsReceiverSharedTLS) map[string]interface{} {
+	u, _ := url.Parse(endpoint)
+	scheme := u.Scheme
+	config := map[string]interface{}{
+		"job_name":        jobName,
+		"scrape_interval": scrapeInterval,
+		"scheme":          scheme,
+		"metrics_path":    u.Path,
+		"static_configs": []map[string]interface{}{{
+			"targets": []string{u.Host},
+		}},
+	}
+	if query := u.Query(); len(query) > 0 {
+		config["params"] = map[string][]string(query)
+	}
+	if scheme == "https" {
+		tlsConfig := map[string]interface{}{}
+		if tls.InsecureSkipVerify != nil {
+			tlsConfig["insecure_skip_verify"] = *tls.InsecureSkipVerify
+		} else if tls.Insecure != nil {
+			tlsConfig["insecure_skip_verify"] = *tls.Insecure
+		}
+		if tls.CertFile != "" {
+			tlsConfig["cert_file"] = tls.CertFile
+		}
+		if tls.KeyFile != "" {
+			tlsConfig["key_file"] = tls.KeyFile
+		}
+		if tls.CAFile != "" {
+			tlsConfig["ca_file"] = tls.CAFile
+		}
+		config["tls_config"] = tlsConfig
+	}
+	return config
+}
+
+// prometheusScrapeReceiver returns a prometheus receiver for the given scrape config.
+func prometheusScrapeReceiver(scrapeConfig map[string]interface{}) otel.Component {
+	return otel.Component{
+		Type: "prometheus",
+		Config: map[string]interface{}{
+			"config": map[string]interface{}{
+				"scrape_configs": []map[string]interface{}{scrapeConfig},
+			},
+		},
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apps
+
+import (
+	"strings"
+
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator"
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/fluentbit"
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/otel"
+)
+
+type MetricsReceiverRabbitmq struct {
+	confgenerator.ConfigComponent `yaml:",inline"`
+
+	confgenerator.MetricsReceiverShared `yaml:",inline"`
+
+	confgenerator.MetricsReceiverSharedTLS `yaml:",inline"`
+
+	Endpoint string `yaml:"endpoint" validate:"omitempty,url"`
+	Username string `yaml:"username" validate:"required"`
+	Password string `yaml:"password" validate:"required"`
+}
+
+const defaultRabbitmqEndpoint = "http://localhost:15672"
+
+func (r MetricsReceiverRabbitmq) Type() string {
+	return "rabbitmq"
+}
+
+func (r MetricsReceiverRabbitmq) Pipelines() []otel.Pipeline {
+	if r.Endpoint == "" {
+		r.Endpoint = defaultRabbitmqEndpoint
+	}
+
+	config := map[string]interface{}{
+		"collection_interval": r.CollectionIntervalString(),
+		"endpoint":            r.Endpoint,
+		"username":            r.Username,
+		"password":            r.Password,
+	}
+	// The management API is served over plain HTTP by default; only set up TLS when it is actually used.
+	if strings.HasPrefix(r.Endpoint, "https://") {
+		config["tls"] = r.TLSConfig(false)
+	}
+
+	return []otel.Pipeline{{
+		Receiver: otel.Component{
+			Type:   "rabbitmq",
+			Config: config,
+		},
+		Processors: []otel.Component{
+			otel.NormalizeSums(),
+			otel.MetricsTransform(
+				otel.AddPrefix("workload.googleapis.com"),
+			),
+		},
+	}}
+}
+
+func init() {
+	confgenerator.MetricsReceiverTypes.RegisterType(func() confgenerator.Component { return &MetricsReceiverRabbitmq{} })
+}
+
+type LoggingProcessorRabbitmq struct {
+	confgenerator.ConfigComponent `yaml:",inline"`
+}
+
+func (LoggingProcessorRabbitmq) Type() string {
+	return "rabbitmq"
+}
+
+func (p LoggingProcessorRabbitmq) Components(tag string, uid string) []fluentbit.Component {
+	c := confgenerator.LoggingProcessorParseMultilineRegex{
+		LoggingProcessorParseRegexComplex: confgenerator.LoggingProcessorParseRegexComplex{
+			Parsers: []confgenerator.RegexParser{
+				{
+					// Documentation: https://www.rabbitmq.com/logging.html#log-file-location
+					// Sample line (RabbitMQ >=3.9): 2022-01-31 18:01:20.441571+00:00 [info] <0.130.0> Successfully synced tables from a peer
+					Regex: `^(?<time>\d{4}-\d{2}-\d{2}\s+\d{2}:\d{2}:\d{2}\.\d+[+-]\d{2}:\d{2})\s+\[(?<level>\w+)\]\s+<(?<processId>\d+\.\d+\.\d+)>\s+(?<message>[\s\S]*)$`,
+					Parser: confgenerator.ParserShared{
+						TimeKey:    "time",
+						TimeFormat: "%Y-%m-%d %H:%M:%S.%L%z",
+					},
+				},
+				{
+					// Sample line (RabbitMQ <3.9): 2021-11-10 10:38:33.264 [info] <0.9.0> Feature flags: list of feature flags found:
+					Regex: `^(?<time>\d{4}-\d{2}-\d{2}\s+\d{2}:\d{2}:\d{2}\.\d+)\s+\[(?<level>\w+)\]\s+<(?<processId>\d+\.\d+\.\d+)>\s+(?<message>[\s\S]*)$`,
+					Parser: confgenerator.ParserShared{
+						TimeKey:    "time",
+						TimeFormat: "%Y-%m-%d %H:%M:%S.%L",
+					},
+				},
+			},
+		},
+		Rules: []confgenerator.MultilineRule{
+			{
+				StateName: "start_state",
+				NextState: "cont",
+				Regex:     `^\d{4}-\d{2}-\d{2}\s+\d{2}:\d{2}:\d{2}\.\d+`,
+			},
+			{
+				StateName: "cont",
+				NextState: "cont",
+				Regex:     `^(?!\d{4}-\d{2}-\d{2}\s+\d{2}:\d{2}:\d{2}\.\d+)`,
+			},
+		},
+	}.Components(tag, uid)
+
+	// Log levels documented: https://www.rabbitmq.com/logging.html#log-levels
+	c = append(c,
+		fluentbit.TranslationComponents(tag, "level", "logging.googleapis.com/severity",
+			[]struct{ SrcVal, DestVal string }{
+				{"debug", "DEBUG"},
+				{"info", "INFO"},
+				{"notice", "NOTICE"},
+				{"warning", "WARNING"},
+				{"error", "ERROR"},
+				{"critical", "CRITICAL"},
+			},
+		)...,
+	)
+
+	return c
+}
+
+type LoggingReceiverRabbitmq struct {
+	LoggingProcessorRabbitmq                `yaml:",inline"`
+	confgenerator.LoggingReceiverFilesMixin `yaml:",inline"`
+}
+
+func (r LoggingReceiverRabbitmq) Components(tag string) []fluentbit.Component {
+	if len(r.IncludePaths) == 0 {
+		r.IncludePaths = []string{
+			// Default log path for Debian / Ubuntu / CentOS / RHEL / SLES
+			"/var/log/rabbitmq/rabbit*.log",
+		}
+	}
+	c := r.LoggingReceiverFilesMixin.Components(tag)
+	c = append(c, r.LoggingProcessorRabbitmq.Components(tag, "rabbitmq")...)
+	return c
+}
+
+func init() {
+	confgenerator.LoggingProcessorTypes.RegisterType(func() confgenerator.Component { return &LoggingProcessorRabbitmq{} })
+	confgenerator.LoggingReceiverTypes.RegisterType(func() confgenerator.Component { return &LoggingReceiverRabbitmq{} })
+}
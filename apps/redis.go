@@ -15,9 +15,12 @@
 package apps
 
 import (
+	"fmt"
+
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator"
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/fluentbit"
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/otel"
+	"github.com/GoogleCloudPlatform/ops-agent/discovery"
 )
 
 type MetricsReceiverRedis struct {
@@ -120,25 +123,28 @@ func (p LoggingProcessorRedis) Components(tag string, uid string) []fluentbit.Co
 	return c
 }
 
+// redisLogPaths are the default log paths of the redis receiver.
+var redisLogPaths = []string{
+	// Default log path on Ubuntu / Debian
+	"/var/log/redis/redis-server.log",
+	// Default log path built from src (6379 is the default redis port)
+	"/var/log/redis_6379.log",
+	// Default log path on CentOS / RHEL
+	"/var/log/redis/redis.log",
+	// Default log path on SLES
+	"/var/log/redis/default.log",
+	// Default log path from one click installer (6379 is the default redis port)
+	"/var/log/redis/redis_6379.log",
+}
+
 type LoggingReceiverRedis struct {
 	LoggingProcessorRedis                   `yaml:",inline"`
-	confgenerator.LoggingReceiverFilesMixin `yaml:",inline" validate:"structonly"`
+	confgenerator.LoggingReceiverFilesMixin `yaml:",inline"`
 }
 
 func (r LoggingReceiverRedis) Components(tag string) []fluentbit.Component {
 	if len(r.IncludePaths) == 0 {
-		r.IncludePaths = []string{
-			// Default log path on Ubuntu / Debian
-			"/var/log/redis/redis-server.log",
-			// Default log path built from src (6379 is the default redis port)
-			"/var/log/redis_6379.log",
-			// Default log path on CentOS / RHEL
-			"/var/log/redis/redis.log",
-			// Default log path on SLES
-			"/var/log/redis/default.log",
-			// Default log path from one click installer (6379 is the default redis port)
-			"/var/log/redis/redis_6379.log",
-		}
+		r.IncludePaths = redisLogPaths
 	}
 	c := r.LoggingReceiverFilesMixin.Components(tag)
 	c = append(c, r.LoggingProcessorRedis.Components(tag, "redis")...)
@@ -149,3 +155,31 @@ func init() {
 	confgenerator.LoggingProcessorTypes.RegisterType(func() confgenerator.Component { return &LoggingProcessorRedis{} })
 	confgenerator.LoggingReceiverTypes.RegisterType(func() confgenerator.Component { return &LoggingReceiverRedis{} })
 }
+
+func init() {
+	discovery.Rules.Register(discovery.Rule{
+		Name: "redis",
+		Match: func(p discovery.Process) bool {
+			return p.Name == "redis-server"
+		},
+		MetricsReceiver: func(d discovery.Detection) confgenerator.MetricsReceiver {
+			return &MetricsReceiverRedis{
+				ConfigComponent:       confgenerator.ConfigComponent{Type: "redis"},
+				MetricsReceiverShared: confgenerator.MetricsReceiverShared{CollectionInterval: "60s"},
+				Address:               fmt.Sprintf("localhost:%d", d.Port(6379)),
+			}
+		},
+		Logs: []discovery.LogSource{
+			{
+				Type:  "redis",
+				Paths: redisLogPaths,
+				Receiver: func(includePaths []string) confgenerator.LoggingReceiver {
+					return &LoggingReceiverRedis{
+						LoggingProcessorRedis:     LoggingProcessorRedis{ConfigComponent: confgenerator.ConfigComponent{Type: "redis"}},
+						LoggingReceiverFilesMixin: confgenerator.LoggingReceiverFilesMixin{IncludePaths: includePaths},
+					}
+				},
+			},
+		},
+	})
+}
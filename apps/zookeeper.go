@@ -0,0 +1,122 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apps
+
+import (
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator"
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/fluentbit"
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/otel"
+)
+
+type MetricsReceiverZookeeper struct {
+	confgenerator.ConfigComponent `yaml:",inline"`
+
+	confgenerator.MetricsReceiverShared `yaml:",inline"`
+
+	Address string `yaml:"address" validate:"omitempty,hostname_port"`
+}
+
+const defaultZookeeperEndpoint = "localhost:2181"
+
+func (r MetricsReceiverZookeeper) Type() string {
+	return "zookeeper"
+}
+
+func (r MetricsReceiverZookeeper) Pipelines() []otel.Pipeline {
+	if r.Address == "" {
+		r.Address = defaultZookeeperEndpoint
+	}
+
+	return []otel.Pipeline{{
+		// The receiver uses the "mntr" four letter word command, which must be allowed by 4lw.commands.whitelist.
+		Receiver: otel.Component{
+			Type: "zookeeper",
+			Config: map[string]interface{}{
+				"collection_interval": r.CollectionIntervalString(),
+				"endpoint":            r.Address,
+			},
+		},
+		Processors: []otel.Component{
+			otel.NormalizeSums(),
+			otel.MetricsTransform(
+				otel.AddPrefix("workload.googleapis.com"),
+			),
+		},
+	}}
+}
+
+func init() {
+	confgenerator.MetricsReceiverTypes.RegisterType(func() confgenerator.Component { return &MetricsReceiverZookeeper{} })
+}
+
+type LoggingProcessorZookeeperGeneral struct {
+	confgenerator.ConfigComponent `yaml:",inline"`
+}
+
+func (LoggingProcessorZookeeperGeneral) Type() string {
+	return "zookeeper_general"
+}
+
+func (p LoggingProcessorZookeeperGeneral) Components(tag string, uid string) []fluentbit.Component {
+	c := javaLogParsingComponents(tag, uid, confgenerator.RegexParser{
+		// Default log4j / logback pattern: %d{ISO8601} [myid:%X{myid}] - %-5p [%t:%C{1}@%L] - %m%n
+		// Sample line: 2021-10-28 20:41:19,424 [myid:] - INFO  [main:QuorumPeerConfig@174] - Reading configuration from: /opt/zookeeper/bin/../conf/zoo.cfg
+		// Sample line: 2021-10-28 20:41:21,121 [myid:1] - WARN  [QuorumPeer[myid=1](plain=0.0.0.0:2181)(secure=disabled):QuorumCnxManager@401] - Cannot open channel to 2 at election address /10.0.0.2:3888
+		// 				java.net.ConnectException: Connection refused (Connection refused)
+		// 					at java.net.PlainSocketImpl.socketConnect(Native Method)
+		Regex: `^(?<time>\d{4}-\d{2}-\d{2}\s+\d{2}:\d{2}:\d{2},\d{3})\s+\[myid:(?<myid>\d*)\]\s+-\s+(?<level>[A-Z]+)\s+\[(?<thread>.+):(?<source>[^@\]]+)@(?<line>\d+)\]\s+-\s+(?<message>[\S\s]*)`,
+		Parser: confgenerator.ParserShared{
+			TimeKey:    "time",
+			TimeFormat: "%Y-%m-%d %H:%M:%S,%L",
+			Types: map[string]string{
+				"line": "integer",
+			},
+		},
+	}, `\d{4}-\d{2}-\d{2}\s+\d{2}:\d{2}:\d{2},\d{3}\s+\[myid:`)
+
+	// log4j has an additional FATAL level that the shared Java levels do not cover.
+	c = append(c,
+		fluentbit.TranslationComponents(tag, "level", "logging.googleapis.com/severity",
+			[]struct{ SrcVal, DestVal string }{
+				{"FATAL", "CRITICAL"},
+			},
+		)...,
+	)
+	return c
+}
+
+type LoggingReceiverZookeeperGeneral struct {
+	LoggingProcessorZookeeperGeneral        `yaml:",inline"`
+	confgenerator.LoggingReceiverFilesMixin `yaml:",inline"`
+}
+
+func (r LoggingReceiverZookeeperGeneral) Components(tag string) []fluentbit.Component {
+	if len(r.IncludePaths) == 0 {
+		r.IncludePaths = []string{
+			// Default log file path for the tarball distribution started with zkServer.sh
+			"/opt/zookeeper/logs/zookeeper-*.out",
+			// Default log file path on Debian / Ubuntu
+			"/var/log/zookeeper/zookeeper.log",
+		}
+	}
+	c := r.LoggingReceiverFilesMixin.Components(tag)
+	c = append(c, r.LoggingProcessorZookeeperGeneral.Components(tag, "zookeeper_general")...)
+	return c
+}
+
+func init() {
+	confgenerator.LoggingProcessorTypes.RegisterType(func() confgenerator.Component { return &LoggingProcessorZookeeperGeneral{} })
+	confgenerator.LoggingReceiverTypes.RegisterType(func() confgenerator.Component { return &LoggingReceiverZookeeperGeneral{} })
+}
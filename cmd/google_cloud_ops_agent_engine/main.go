@@ -22,6 +22,8 @@ import (
 
 	"github.com/GoogleCloudPlatform/ops-agent/apps"
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator"
+	"github.com/GoogleCloudPlatform/ops-agent/discovery"
+	yaml "github.com/goccy/go-yaml"
 )
 
 var (
@@ -34,14 +36,35 @@ var (
 
 func main() {
 	flag.Parse()
+	// "discover" prints the config that "auto_discover: true" would add, without generating anything.
+	if flag.Arg(0) == "discover" {
+		if err := discover(); err != nil {
+			log.Fatalf("Failed to discover applications. Detailed error: %s", err)
+		}
+		return
+	}
 	if err := run(); err != nil {
 		log.Fatalf("The agent config file is not valid. Detailed error: %s", err)
 	}
 }
+
+func discover() error {
+	uc, err := discovery.Discover()
+	if err != nil {
+		return err
+	}
+	out, err := yaml.Marshal(uc)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
 func run() error {
 	// TODO(lingshi) Move this to a shared place across Linux and Windows.
 	confDebugFolder := filepath.Join(os.Getenv("RUNTIME_DIRECTORY"), "conf", "debug")
-	if err := confgenerator.MergeConfFiles(*input, confDebugFolder, "linux", apps.BuiltInConfStructs); err != nil {
+	if err := confgenerator.MergeConfFiles(*input, confDebugFolder, "linux", apps.BuiltInConfStructs, discovery.Discover); err != nil {
 		return err
 	}
 	return confgenerator.GenerateFiles(filepath.Join(confDebugFolder, "merged-config.yaml"), *service, *logsDir, *stateDir, *outDir)
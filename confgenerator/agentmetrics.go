@@ -85,4 +85,46 @@ func (r MetricsReceiverAgent) Pipeline() otel.Pipeline {
 	}
 }
 
+// LoggingPipeline provides the agent.googleapis.com/agent/log_entry_dropped_count metric, which counts the records
+// dropped by the processors that limit the volume of logs. It is scraped from the built-in HTTP server of Fluent Bit
+// at `address`.
+func (r MetricsReceiverAgent) LoggingPipeline(address string) otel.Pipeline {
+	return otel.Pipeline{
+		Receiver: otel.Component{
+			Type: "prometheus",
+			Config: map[string]interface{}{
+				"config": map[string]interface{}{
+					"scrape_configs": []map[string]interface{}{{
+						"job_name":        "logging-module",
+						"scrape_interval": "1m",
+						"metrics_path":    "/api/v1/metrics/prometheus",
+						"static_configs": []map[string]interface{}{{
+							"targets": []string{address},
+						}},
+						// Only keep the drop counts of the filters of those processors, and not the ones of the
+						// filters that drop records as part of parsing, such as grep.
+						"metric_relabel_configs": []map[string]interface{}{{
+							"source_labels": []string{"__name__", "name"},
+							"regex":         "fluentbit_filter_drop_records_total;" + droppingFilterAliasRegex(),
+							"action":        "keep",
+						}},
+					}},
+				},
+			},
+		},
+		Processors: []otel.Component{
+			otel.MetricsTransform(
+				otel.RenameMetric("fluentbit_filter_drop_records_total", "agent/log_entry_dropped_count",
+					// change data type from double -> int64
+					otel.ToggleScalarDataType,
+					otel.RenameLabel("name", "filter"),
+					// retain only the filter label
+					otel.AggregateLabels("sum", "filter"),
+				),
+				otel.AddPrefix("agent.googleapis.com"),
+			),
+		},
+	}
+}
+
 // intentionally not registered as a component because this is not created by users
@@ -18,7 +18,6 @@ package confgenerator
 import (
 	"fmt"
 	"path"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -42,9 +41,13 @@ func (uc *UnifiedConfig) GenerateOtelConfig(hostInfo *host.InfoStat) (string, er
 		}
 	}
 
-	pipelines["agent"] = MetricsReceiverAgent{
+	agent := MetricsReceiverAgent{
 		Version: versionLabel,
-	}.Pipeline()
+	}
+	pipelines["agent"] = agent.Pipeline()
+	if uc.Logging.dropsRecords() {
+		pipelines["agent_logging"] = agent.LoggingPipeline(uc.Logging.monitoringScrapeAddress())
+	}
 
 	if uc.Metrics.Service.LogLevel == "" {
 		uc.Metrics.Service.LogLevel = "info"
@@ -128,7 +131,7 @@ func (l *Logging) generateFluentbitComponents(userAgent string, hostInfo *host.I
 	if l.Service.LogLevel == "" {
 		l.Service.LogLevel = "info"
 	}
-	out = append(out, fluentbit.Service{LogLevel: l.Service.LogLevel}.Component())
+	out = append(out, l.serviceComponent())
 
 	if l != nil && l.Service != nil {
 		// Type for sorting.
@@ -137,7 +140,6 @@ func (l *Logging) generateFluentbitComponents(userAgent string, hostInfo *host.I
 			components []fluentbit.Component
 		}
 		var sources []fbSource
-		var logNames []string
 		for pID, p := range l.Service.Pipelines {
 			for _, rID := range p.ReceiverIDs {
 				receiver, ok := l.Receivers[rID]
@@ -146,6 +148,10 @@ func (l *Logging) generateFluentbitComponents(userAgent string, hostInfo *host.I
 				}
 				tag := fmt.Sprintf("%s.%s", pID, rID)
 				components := receiver.Components(tag)
+				var postComponents []fluentbit.Component
+				if r, ok := receiver.(postProcessor); ok {
+					postComponents = r.postProcessorComponents(tag)
+				}
 				for i, pID := range p.ProcessorIDs {
 					processor, ok := l.Processors[pID]
 					if !ok {
@@ -154,27 +160,32 @@ func (l *Logging) generateFluentbitComponents(userAgent string, hostInfo *host.I
 					if !ok {
 						return nil, fmt.Errorf("processor %q not found", pID)
 					}
-					components = append(components, processor.Components(tag, strconv.Itoa(i))...)
+					processorComponents := processor.Components(tag, strconv.Itoa(i))
+					if len(postComponents) > 0 {
+						reserveData(processorComponents)
+					}
+					components = append(components, processorComponents...)
+				}
+				components = append(components, postComponents...)
+				logName := rID
+				if r, ok := receiver.(logNamer); ok && r.logName() != "" {
+					logName = r.logName()
 				}
-				components = append(components, setLogNameComponents(tag, rID)...)
-				logNames = append(logNames, regexp.QuoteMeta(rID))
+				components = append(components, setLogNameComponents(tag, logName, rID)...)
 				sources = append(sources, fbSource{tag, components})
 			}
 		}
 		sort.Slice(sources, func(i, j int) bool { return sources[i].tag < sources[j].tag })
-		sort.Strings(logNames)
 
 		for _, s := range sources {
 			out = append(out, s.components...)
 		}
-		if len(logNames) > 0 {
-			out = append(out, stackdriverOutputComponent(strings.Join(logNames, "|"), userAgent))
-		}
 	}
 	out = append(out, LoggingReceiverFilesMixin{
 		IncludePaths: []string{"${logs_dir}/logging-module.log"},
 	}.Components("ops-agent-fluent-bit")...)
-	out = append(out, stackdriverOutputComponent("ops-agent-fluent-bit", userAgent))
+	// The logs of the agent are named after their tag.
+	out = append(out, stackdriverOutputComponent("*", userAgent, l.Service))
 
 	return out, nil
 }
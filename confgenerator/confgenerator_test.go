@@ -25,6 +25,7 @@ import (
 
 	"github.com/GoogleCloudPlatform/ops-agent/apps"
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator"
+	"github.com/GoogleCloudPlatform/ops-agent/discovery"
 	"github.com/google/go-cmp/cmp"
 	"github.com/shirou/gopsutil/host"
 )
@@ -52,6 +53,37 @@ var (
 	mergedInputPath   = invalidTestdataDir + "/%s/%s/merged-config.yaml"
 )
 
+// fakeHost is the host that "auto_discover" test configs are run against.
+var fakeHost = &discovery.Host{
+	Hostname: "test-host",
+	Processes: []discovery.Process{
+		{PID: 100, PPID: 1, Name: "apache2", Cmdline: []string{"/usr/sbin/apache2", "-k", "start"}},
+		{PID: 101, PPID: 100, Name: "apache2", Cmdline: []string{"/usr/sbin/apache2", "-k", "start"}},
+		{PID: 200, PPID: 1, Name: "redis-server", Cmdline: []string{"/usr/bin/redis-server", "127.0.0.1:6380"}},
+		{PID: 300, PPID: 1, Name: "java", Cmdline: []string{"java", "-Dcassandra.jmx.local.port=7299", "org.apache.cassandra.service.CassandraDaemon"}},
+		{PID: 400, PPID: 1, Name: "java", Cmdline: []string{"java", "-Dcom.sun.management.jmxremote.port=9010", "-jar", "app.jar"}},
+		{PID: 500, PPID: 1, Name: "mysqld", Cmdline: []string{"/usr/sbin/mysqld"}},
+	},
+	ListeningPorts: map[int32][]int{
+		100: {8080},
+		200: {6380},
+		300: {7299, 9042},
+		400: {9010},
+		500: {3306},
+	},
+	Glob: func(pattern string) ([]string, error) {
+		switch pattern {
+		case "/var/log/apache2/access.log", "/var/log/apache2/error.log", "/var/log/mysql/error.log", "/var/lib/mysql/test-host.log":
+			return []string{pattern}, nil
+		}
+		return nil, nil
+	},
+}
+
+func fakeDiscover() (*confgenerator.UnifiedConfig, error) {
+	return discovery.Propose(fakeHost), nil
+}
+
 type platformConfig struct {
 	defaultLogsDir  string
 	defaultStateDir string
@@ -108,7 +140,8 @@ func testGenerateConfsWithValidInput(t *testing.T, platform platformConfig) {
 			userSpecifiedConfPath := filepath.Join(confDebugFolder, "/input.yaml")
 			builtInConfPath := filepath.Join(confDebugFolder, "/built-in-config.yaml")
 			mergedConfPath := filepath.Join(confDebugFolder, "/merged-config.yaml")
-			if err = confgenerator.MergeConfFiles(userSpecifiedConfPath, confDebugFolder, platform.OS, apps.BuiltInConfStructs); err != nil {
+			discoveredConfPath := filepath.Join(confDebugFolder, "/discovered-config.yaml")
+			if err = confgenerator.MergeConfFiles(userSpecifiedConfPath, confDebugFolder, platform.OS, apps.BuiltInConfStructs, fakeDiscover); err != nil {
 				t.Fatalf("MergeConfFiles(%q, %q) got: %v", userSpecifiedConfPath, confDebugFolder, err)
 			}
 
@@ -143,6 +176,32 @@ func testGenerateConfsWithValidInput(t *testing.T, platform platformConfig) {
 			// Compare the expected and actual and error out in case of diff.
 			updateOrCompareGolden(t, testName, platform.OS, expectedOtelConfig, otelConf, goldenOtelPath)
 
+			// Compare the expected and generated Lua scripts, which are written next to the main config.
+			outDir := t.TempDir()
+			if err := confgenerator.GenerateFilesFromConfig(&uc, "fluentbit", platform.defaultLogsDir, platform.defaultStateDir, outDir); err != nil {
+				t.Fatalf("GenerateFilesFromConfig got: %v", err)
+			}
+			scripts, err := filepath.Glob(filepath.Join(outDir, "*.lua"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, script := range scripts {
+				goldenScriptPath := validTestdataDir + "/%s/%s/golden_" + filepath.Base(script)
+				expectedScript := readFileContent(t, testName, platform.OS, goldenScriptPath, true)
+				actualScript, err := ioutil.ReadFile(script)
+				if err != nil {
+					t.Fatalf("ReadFile(%q) got: %v", script, err)
+				}
+				updateOrCompareGolden(t, testName, platform.OS, expectedScript, string(actualScript), goldenScriptPath)
+			}
+			goldenScripts, err := filepath.Glob(filepath.Join(confDebugFolder, "golden_*.lua"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(goldenScripts) != len(scripts) {
+				t.Errorf("test %q: got %d Lua scripts, want %d: %q", testName, len(scripts), len(goldenScripts), goldenScripts)
+			}
+
 			// Compare the expected and generated built-in config and error out in case of diff.
 			if testName == builtInConfTestName {
 				expectedBuiltInConfig := readFileContent(t, testName, platform.OS, goldenBuiltInPath, true)
@@ -158,6 +217,9 @@ func testGenerateConfsWithValidInput(t *testing.T, platform platformConfig) {
 			if err = os.Remove(mergedConfPath); err != nil {
 				t.Fatalf("DeleteFile(%q) got: %v", mergedConfPath, err)
 			}
+			if err = os.Remove(discoveredConfPath); err != nil && !os.IsNotExist(err) {
+				t.Fatalf("DeleteFile(%q) got: %v", discoveredConfPath, err)
+			}
 		})
 	}
 }
@@ -219,14 +281,15 @@ func testGenerateConfigsWithInvalidInput(t *testing.T, platform platformConfig)
 			userSpecifiedConfPath := filepath.Join(confDebugFolder, "/input.yaml")
 			builtInConfPath := filepath.Join(confDebugFolder, "/built-in-config.yaml")
 			mergedConfPath := filepath.Join(confDebugFolder, "/merged-config.yaml")
+			discoveredConfPath := filepath.Join(confDebugFolder, "/discovered-config.yaml")
 
 			invalidInput := readFileContent(t, testName, platform.OS, invalidInputPath, false)
 			expectedError := readFileContent(t, testName, platform.OS, goldenErrorPath, true)
 
-			actualError := confgenerator.MergeConfFiles(userSpecifiedConfPath, confDebugFolder, platform.OS, apps.BuiltInConfStructs)
+			actualError := confgenerator.MergeConfFiles(userSpecifiedConfPath, confDebugFolder, platform.OS, apps.BuiltInConfStructs, fakeDiscover)
 			if actualError == nil {
 				mergedInput := readFileContent(t, testName, platform.OS, mergedInputPath, false)
-				actualError = generateConfigs(mergedInput, platform)
+				actualError = generateConfigs(mergedInput, platform, t.TempDir())
 			}
 			if actualError == nil {
 				t.Errorf("test %q: generateConfigs succeeded, want error:\n%s\ninvalid input:\n%s", testName, expectedError, invalidInput)
@@ -239,6 +302,7 @@ func testGenerateConfigsWithInvalidInput(t *testing.T, platform platformConfig)
 				t.Fatalf("DeleteFile(%q) got: %v", builtInConfPath, err)
 			}
 			os.Remove(mergedConfPath)
+			os.Remove(discoveredConfPath)
 		})
 	}
 }
@@ -247,13 +311,14 @@ func testGenerateConfigsWithInvalidInput(t *testing.T, platform platformConfig)
 // 1. Parsing phase of the agent config when the config is not YAML.
 // 2. Config generation phase when the config is invalid.
 // If at any point, an error is generated, immediately return it for validation.
-func generateConfigs(invalidInput []byte, platform platformConfig) (err error) {
+func generateConfigs(invalidInput []byte, platform platformConfig, outDir string) (err error) {
 	uc, err := confgenerator.ParseUnifiedConfigAndValidate(invalidInput, platform.OS)
 	if err != nil {
 		return err
 	}
 
-	if _, _, err := uc.GenerateFluentBitConfigs(platform.defaultLogsDir, platform.defaultStateDir, platform.InfoStat); err != nil {
+	// GenerateFilesFromConfig also reads the Lua scripts.
+	if err := confgenerator.GenerateFilesFromConfig(&uc, "fluentbit", platform.defaultLogsDir, platform.defaultStateDir, outDir); err != nil {
 		return err
 	}
 
@@ -262,3 +327,33 @@ func generateConfigs(invalidInput []byte, platform platformConfig) (err error) {
 	}
 	return nil
 }
+
+func TestMergeConfFilesWithFailingDiscoverer(t *testing.T) {
+	confDebugFolder := t.TempDir()
+	userConfPath := filepath.Join(validTestdataDir, "linux", "auto_discover_with_configured_app", "input.yaml")
+	failingDiscover := func() (*confgenerator.UnifiedConfig, error) {
+		return nil, fmt.Errorf("cannot list processes")
+	}
+	if err := confgenerator.MergeConfFiles(userConfPath, confDebugFolder, "linux", apps.BuiltInConfStructs, failingDiscover); err != nil {
+		t.Fatalf("MergeConfFiles got: %v, want the discovery error to be ignored", err)
+	}
+	if _, err := os.Stat(filepath.Join(confDebugFolder, "discovered-config.yaml")); !os.IsNotExist(err) {
+		t.Errorf("Stat(discovered-config.yaml) got: %v, want a not exist error", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(confDebugFolder, "merged-config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc, err := confgenerator.ParseUnifiedConfigAndValidate(data, "linux")
+	if err != nil {
+		t.Fatalf("ParseUnifiedConfigAndValidate got: %v", err)
+	}
+	if _, ok := uc.Metrics.Receivers["redis"]; !ok {
+		t.Errorf("merged config is missing the user receiver %q:\n%s", "redis", data)
+	}
+	for id := range uc.Metrics.Receivers {
+		if strings.HasPrefix(id, "discovered_") {
+			t.Errorf("merged config has the discovered receiver %q:\n%s", id, data)
+		}
+	}
+}
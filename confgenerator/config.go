@@ -18,10 +18,13 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/url"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/fluentbit"
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/otel"
@@ -31,8 +34,10 @@ import (
 
 // Ops Agent config.
 type UnifiedConfig struct {
-	Logging *Logging `yaml:"logging"`
-	Metrics *Metrics `yaml:"metrics"`
+	// AutoDiscover enables receivers for the applications found running on the host.
+	AutoDiscover bool     `yaml:"auto_discover,omitempty"`
+	Logging      *Logging `yaml:"logging"`
+	Metrics      *Metrics `yaml:"metrics"`
 }
 
 func (uc *UnifiedConfig) HasLogging() bool {
@@ -82,24 +87,95 @@ func (ve validationError) StructField() string {
 	return parts[0]
 }
 
+// yamlFieldName converts the Go name of a field in a validation parameter, e.g. "ScriptPath" or "TLSVerify", to its YAML
+// name, e.g. "script_path" or "tls_verify".
+func yamlFieldName(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// A word starts at an upper case letter that follows a lower case one, or that ends an acronym.
+			if i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
 func (ve validationError) Error() string {
 	switch ve.Tag() {
+	case "contains":
+		return fmt.Sprintf("%q must contain %q", ve.Field(), ve.Param())
+	case "delimiter":
+		return fmt.Sprintf("%q must be a single character or \\t", ve.Field())
 	case "duration":
 		return fmt.Sprintf("%q must be a duration of at least %s", ve.Field(), ve.Param())
 	case "endswith":
 		return fmt.Sprintf("%q must end with %q", ve.Field(), ve.Param())
+	case "excludes":
+		return fmt.Sprintf("%q must not contain %q", ve.Field(), ve.Param())
+	case "excluded_with":
+		return fmt.Sprintf("%q must not be set when %q is set", ve.Field(), yamlFieldName(ve.Param()))
+	case "excluded_if_false":
+		return fmt.Sprintf("%q must not be set when %q is false", ve.Field(), yamlFieldName(ve.Param()))
+	case "fieldname":
+		return fmt.Sprintf("%q must only contain letters, digits and underscores, and not start with a digit", ve.Field())
+	case "hostname_port":
+		return fmt.Sprintf("%q must be in the form <host>:<port>", ve.Field())
+	case "http_url":
+		return fmt.Sprintf("%q must be an http or https URL", ve.Field())
 	case "ip":
 		return fmt.Sprintf("%q must be an IP address", ve.Field())
+	case "key_value_delimiters":
+		return "\"field_delimiter\" and \"value_delimiter\" must not overlap"
+	case "log_name":
+		return fmt.Sprintf("%q must only contain letters, digits and the characters _./-, or ${field} references", ve.Field())
+	case "lua_pattern":
+		return fmt.Sprintf("%q must be a valid Lua pattern", ve.Field())
+	case "max":
+		return fmt.Sprintf("%q must be at most %s", ve.Field(), ve.Param())
+	case "min":
+		return fmt.Sprintf("%q must be at least %s", ve.Field(), ve.Param())
+	case "modify_arg":
+		return fmt.Sprintf("%q must not contain double quotes or line breaks", ve.Field())
+	case "multiline_next_states":
+		return fmt.Sprintf("%q must have rules for every next_state", ve.Field())
+	case "multiline_start_state":
+		return fmt.Sprintf("%q must have a rule for \"start_state\"", ve.Field())
 	case "oneof":
 		return fmt.Sprintf("%q must be one of [%s]", ve.Field(), ve.Param())
+	case "regexp", "regexp_if":
+		return fmt.Sprintf("%q must be a valid regular expression", ve.Field())
+	case "required_if_oneof":
+		params := strings.Fields(ve.Param())
+		return fmt.Sprintf("%q is required when %q is one of [%s]", ve.Field(), yamlFieldName(params[0]), strings.Join(params[1:], " "))
 	case "required":
 		return fmt.Sprintf("%q is a required field", ve.Field())
+	case "required_without":
+		return fmt.Sprintf("%q is required when %q is not set", ve.Field(), yamlFieldName(ve.Param()))
+	case "required_without_all":
+		var fields []string
+		for _, f := range strings.Fields(ve.Param()) {
+			fields = append(fields, yamlFieldName(f))
+		}
+		return fmt.Sprintf("%q is required when none of [%s] are set", ve.Field(), strings.Join(fields, " "))
+	case "resource_label":
+		return fmt.Sprintf("%q must not contain \",\" or \"=\"", ve.Field())
+	case "retry_limit":
+		return fmt.Sprintf("%q must be a positive number or \"unlimited\"", ve.Field())
+	case "size":
+		return fmt.Sprintf("%q must be a size, such as 512k or 5M", ve.Field())
 	case "startsnotwith":
 		return fmt.Sprintf("%q must not start with %q", ve.Field(), ve.Param())
 	case "startswith":
 		return fmt.Sprintf("%q must start with %q", ve.Field(), ve.Param())
 	case "url":
 		return fmt.Sprintf("%q must be a URL", ve.Field())
+	case "whole_seconds":
+		return fmt.Sprintf("%q must be a whole number of seconds", ve.Field())
 	}
 
 	return ve.FieldError.Error()
@@ -124,6 +200,18 @@ type platformKeyType struct{}
 // platformKey is a singleton that is used as a Context key for retrieving the current platform from the context.Context.
 var platformKey = platformKeyType{}
 
+// fieldNameRegexp matches the names that can be used for regex capture groups.
+var fieldNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// logNameRegexp matches the log names accepted by Cloud Logging, with "${field}" references.
+var logNameRegexp = regexp.MustCompile(`^(?:[A-Za-z0-9_./-]|\$\{[A-Za-z0-9_]+\})+$`)
+
+// retryLimitRegexp matches the retry limits of the logging output.
+var retryLimitRegexp = regexp.MustCompile(`^(?:[1-9][0-9]*|unlimited)$`)
+
+// sizeRegexp matches the sizes accepted by fluentbit.
+var sizeRegexp = regexp.MustCompile(`^[0-9]+[kKmMgG]?$`)
+
 func newValidator() *validator.Validate {
 	v := validator.New()
 	v.RegisterTagNameFunc(func(fld reflect.StructField) string {
@@ -149,6 +237,96 @@ func newValidator() *validator.Validate {
 		}
 		return t >= tmin
 	})
+	// whole_seconds validates that the value is a duration without a fraction of a second
+	v.RegisterValidation("whole_seconds", func(fl validator.FieldLevel) bool {
+		t, err := time.ParseDuration(fl.Field().String())
+		return err == nil && t%time.Second == 0
+	})
+	// delimiter validates that the value is a single character or `\t`
+	v.RegisterValidation("delimiter", func(fl validator.FieldLevel) bool {
+		d := fl.Field().String()
+		return len(d) == 1 || d == `\t`
+	})
+	// resource_label validates that the value can be passed in the resource_labels of the stackdriver output, which are
+	// separated by "," and "="
+	v.RegisterValidation("resource_label", func(fl validator.FieldLevel) bool {
+		return !strings.ContainsAny(fl.Field().String(), ",=")
+	})
+	// retry_limit validates that the value is a positive number of retries or "unlimited"
+	v.RegisterValidation("retry_limit", func(fl validator.FieldLevel) bool {
+		return retryLimitRegexp.MatchString(fl.Field().String())
+	})
+	// size validates that the value is a size in bytes, with an optional unit, e.g. "512k" or "5M"
+	v.RegisterValidation("size", func(fl validator.FieldLevel) bool {
+		return sizeRegexp.MatchString(fl.Field().String())
+	})
+	// key_value_delimiters validates that the delimiters of a parse_key_value processor don't overlap
+	v.RegisterValidation("key_value_delimiters", func(fl validator.FieldLevel) bool {
+		p := fl.Parent().Interface().(LoggingProcessorParseKeyValue)
+		return !delimitersOverlap(p.fieldDelimiter(), p.valueDelimiter())
+	})
+	// log_name validates that the value is a valid log name, where "${field}" references a field of the record
+	v.RegisterValidation("log_name", func(fl validator.FieldLevel) bool {
+		return logNameRegexp.MatchString(fl.Field().String())
+	})
+	// http_url validates that the value is an absolute http or https URL with a host
+	v.RegisterValidation("http_url", func(fl validator.FieldLevel) bool {
+		u, err := url.Parse(fl.Field().String())
+		return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+	})
+	// lua_pattern validates that the value is a Lua pattern
+	v.RegisterValidation("lua_pattern", func(fl validator.FieldLevel) bool {
+		err := luaPatternError(fl.Field().String())
+		return err == nil
+	})
+	// modify_arg validates that the value can be passed to the fluentbit modify filter, which can't escape double quotes or line breaks
+	v.RegisterValidation("modify_arg", func(fl validator.FieldLevel) bool {
+		return !strings.ContainsAny(fl.Field().String(), "\"\r\n")
+	})
+	// excluded_if_false validates that the value is not set when the *bool sibling field named by the parameter is false
+	v.RegisterValidation("excluded_if_false", func(fl validator.FieldLevel) bool {
+		sibling := fl.Parent().FieldByName(fl.Param())
+		return fl.Field().IsZero() || sibling.IsNil() || sibling.Elem().Bool()
+	})
+	// fieldname validates that the value can be used as a field name in a regex capture group
+	v.RegisterValidation("fieldname", func(fl validator.FieldLevel) bool {
+		return fieldNameRegexp.MatchString(fl.Field().String())
+	})
+	// multiline_start_state validates that multiline rules have a rule for "start_state"
+	v.RegisterValidation("multiline_start_state", func(fl validator.FieldLevel) bool {
+		return multilineStartState(fl.Field().Interface().([]MultilineRule))
+	})
+	// multiline_next_states validates that multiline rules have rules for every next state
+	v.RegisterValidation("multiline_next_states", func(fl validator.FieldLevel) bool {
+		return multilineNextStates(fl.Field().Interface().([]MultilineRule))
+	})
+	// required_if_oneof validates that the value is set when the sibling field named by the first word of the parameter
+	// has one of the values given by the remaining words.
+	v.RegisterValidation("required_if_oneof", func(fl validator.FieldLevel) bool {
+		params := strings.Fields(fl.Param())
+		other := fl.Parent().FieldByName(params[0]).String()
+		for _, value := range params[1:] {
+			if other == value {
+				return !fl.Field().IsZero()
+			}
+		}
+		return true
+	})
+	// regexp validates that the value compiles as a regexp
+	v.RegisterValidation("regexp", func(fl validator.FieldLevel) bool {
+		_, err := regexp.Compile(fl.Field().String())
+		return err == nil
+	})
+	// regexp_if validates that the value compiles as a regexp when the sibling field named by the first word of the parameter
+	// is equal to the second word.
+	v.RegisterValidation("regexp_if", func(fl validator.FieldLevel) bool {
+		params := strings.Fields(fl.Param())
+		if fl.Parent().FieldByName(params[0]).String() != params[1] {
+			return true
+		}
+		_, err := regexp.Compile(fl.Field().String())
+		return err == nil
+	})
 	return v
 }
 
@@ -329,9 +507,50 @@ func (m *loggingProcessorMap) UnmarshalYAML(unmarshal func(interface{}) error) e
 
 type LoggingService struct {
 	LogLevel  string                      `yaml:"log_level,omitempty" validate:"omitempty,oneof=error warn info debug trace"`
+	Settings  *LoggingServiceSettings     `yaml:"settings,omitempty"`
+	Output    *LoggingServiceOutput       `yaml:"output,omitempty"`
 	Pipelines map[string]*LoggingPipeline `validate:"dive,keys,startsnotwith=lib:"`
 }
 
+// LoggingServiceOutput holds the settings of the Cloud Logging output.
+type LoggingServiceOutput struct {
+	// RetryLimit is the number of times a chunk of logs is retried before being dropped, or "unlimited".
+	// "unlimited" requires a settings.buffer_limit, so that the buffer cannot fill the disk.
+	RetryLimit string `yaml:"retry_limit,omitempty" validate:"omitempty,retry_limit"`
+	Workers    int    `yaml:"workers,omitempty" validate:"omitempty,min=1"`
+	// TLSVerify controls the verification of the certificate of the Cloud Logging API, against TLSCAFile if set.
+	// The certificate is verified unless TLSVerify is explicitly false, in which case TLSCAFile must not be set.
+	TLSVerify *bool  `yaml:"tls_verify,omitempty"`
+	TLSCAFile string `yaml:"tls_ca_file,omitempty" validate:"excluded_if_false=TLSVerify"`
+	Compress  string `yaml:"compress,omitempty" validate:"omitempty,oneof=gzip"`
+	// ResourceType and ResourceLabels describe the monitored resource of the logs, e.g. "generic_node" for hosts outside of GCE.
+	// The labels required by the resource type are listed in requiredResourceLabels.
+	ResourceType   string            `yaml:"resource_type,omitempty" validate:"omitempty,oneof=gce_instance generic_node generic_task global"`
+	ResourceLabels map[string]string `yaml:"resource_labels,omitempty" validate:"dive,keys,required,resource_label,endkeys,required,resource_label"`
+}
+
+// requiredResourceLabels are the labels that the monitored resource types require, see
+// https://cloud.google.com/logging/docs/api/v2/resource-list.
+var requiredResourceLabels = map[string][]string{
+	"generic_node": {"location", "namespace", "node_id"},
+	"generic_task": {"location", "namespace", "job", "task_id"},
+}
+
+// LoggingServiceSettings holds the settings of the Fluent Bit service.
+type LoggingServiceSettings struct {
+	// FlushInterval is how often the buffered logs are sent.
+	FlushInterval string `yaml:"flush_interval,omitempty" validate:"omitempty,duration=1s"`
+	// BufferLimit caps the size of the logs buffered on disk for the output, e.g. "2G".
+	// The oldest logs are dropped when the limit is reached.
+	BufferLimit string `yaml:"buffer_limit,omitempty" validate:"omitempty,size"`
+	// BacklogMemLimit caps the memory used to load the logs buffered by previous runs.
+	BacklogMemLimit string `yaml:"backlog_mem_limit,omitempty" validate:"omitempty,size"`
+	// MaxChunksUp caps the number of buffered chunks that are loaded in memory.
+	MaxChunksUp int `yaml:"max_chunks_up,omitempty" validate:"omitempty,min=1"`
+	// MonitoringListenAddress is the address of the built-in HTTP server, which serves the metrics of the subagent.
+	MonitoringListenAddress string `yaml:"monitoring_listen_address,omitempty" validate:"omitempty,hostname_port"`
+}
+
 type LoggingPipeline struct {
 	ReceiverIDs  []string `yaml:"receivers,omitempty,flow"`
 	ProcessorIDs []string `yaml:"processors,omitempty,flow"`
@@ -367,6 +586,39 @@ func (m MetricsReceiverShared) CollectionIntervalString() string {
 	return "60s"
 }
 
+// MetricsReceiverSharedTLS holds the TLS settings for metrics receivers that connect to an endpoint over HTTPS.
+type MetricsReceiverSharedTLS struct {
+	Insecure           *bool  `yaml:"insecure,omitempty"`
+	InsecureSkipVerify *bool  `yaml:"insecure_skip_verify,omitempty"`
+	CertFile           string `yaml:"cert_file,omitempty"`
+	KeyFile            string `yaml:"key_file,omitempty"`
+	CAFile             string `yaml:"ca_file,omitempty"`
+}
+
+// TLSConfig returns the "tls" section of an OT receiver config.
+// defaultInsecure is used when the user has not set "insecure".
+func (m MetricsReceiverSharedTLS) TLSConfig(defaultInsecure bool) map[string]interface{} {
+	tls := map[string]interface{}{
+		"insecure": defaultInsecure,
+	}
+	if m.Insecure != nil {
+		tls["insecure"] = *m.Insecure
+	}
+	if m.InsecureSkipVerify != nil {
+		tls["insecure_skip_verify"] = *m.InsecureSkipVerify
+	}
+	if m.CertFile != "" {
+		tls["cert_file"] = m.CertFile
+	}
+	if m.KeyFile != "" {
+		tls["key_file"] = m.KeyFile
+	}
+	if m.CAFile != "" {
+		tls["ca_file"] = m.CAFile
+	}
+	return tls
+}
+
 var MetricsReceiverTypes = &componentTypeRegistry{
 	Subagent: "metrics", Kind: "receiver",
 }
@@ -442,6 +694,10 @@ type MetricsPipeline struct {
 }
 
 func (uc *UnifiedConfig) Validate(platform string) error {
+	if uc.AutoDiscover && platform != "linux" {
+		// The discovery rules only know about Linux process names and log paths.
+		return fmt.Errorf(`"auto_discover" is only supported on Linux`)
+	}
 	if uc.Logging != nil {
 		if err := uc.Logging.Validate(platform); err != nil {
 			return err
@@ -463,6 +719,16 @@ func (l *Logging) Validate(platform string) error {
 	if l.Service == nil {
 		return nil
 	}
+	if o := l.Service.Output; o != nil && o.RetryLimit == "unlimited" && (l.Service.Settings == nil || l.Service.Settings.BufferLimit == "") {
+		return fmt.Errorf(`"unlimited" retries require "logging.service.settings.buffer_limit" to be set`)
+	}
+	if o := l.Service.Output; o != nil {
+		for _, label := range requiredResourceLabels[o.ResourceType] {
+			if o.ResourceLabels[label] == "" {
+				return fmt.Errorf(`resource type %q requires "logging.service.output.resource_labels.%s" to be set`, o.ResourceType, label)
+			}
+		}
+	}
 	for _, id := range sortedKeys(l.Service.Pipelines) {
 		p := l.Service.Pipelines[id]
 		if err := validateComponentKeys(l.Receivers, p.ReceiverIDs, subagent, "receiver", id); err != nil {
@@ -567,6 +833,14 @@ func mapKeys(m interface{}) map[string]bool {
 		for k := range m {
 			keys[k] = true
 		}
+	case map[string]string:
+		for k := range m {
+			keys[k] = true
+		}
+	case map[string]bool:
+		for k := range m {
+			keys[k] = true
+		}
 	default:
 		panic(fmt.Sprintf("Unknown type: %T", m))
 	}
@@ -626,8 +900,10 @@ func validateComponentTypeCounts(components interface{}, refs []string, subagent
 	return r, nil
 }
 
+// jvmReceivers are the metrics receiver types that use the JMX receiver. They currently conflict with each other.
+var jvmReceivers = []string{"jvm", "cassandra"}
+
 func validateIncompatibleJVMReceivers(typeCounts map[string]int) error {
-	jvmReceivers := []string{"jvm", "cassandra"}
 	jvmReceiverCount := 0
 	for _, receiverType := range jvmReceivers {
 		jvmReceiverCount += typeCounts[receiverType]
@@ -18,19 +18,27 @@ package confgenerator
 import (
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
 
 	yaml "github.com/goccy/go-yaml"
 )
 
-func MergeConfFiles(userConfPath, confDebugFolder, platform string, builtInConfStructs map[string]*UnifiedConfig) error {
+// A Discoverer proposes a config for the applications running on the host.
+type Discoverer func() (*UnifiedConfig, error)
+
+// MergeConfFiles merges the user config on top of the built-in config.
+// If the user config sets "auto_discover", discover is called and its proposed config is merged in between,
+// so that the user config takes precedence. discover may be nil to disable discovery.
+func MergeConfFiles(userConfPath, confDebugFolder, platform string, builtInConfStructs map[string]*UnifiedConfig, discover Discoverer) error {
 	builtInConfPath := filepath.Join(confDebugFolder, "built-in-config.yaml")
+	discoveredConfPath := filepath.Join(confDebugFolder, "discovered-config.yaml")
 	mergedConfPath := filepath.Join(confDebugFolder, "merged-config.yaml")
-	return mergeConfFiles(builtInConfPath, userConfPath, mergedConfPath, platform, builtInConfStructs)
+	return mergeConfFiles(builtInConfPath, userConfPath, discoveredConfPath, mergedConfPath, platform, builtInConfStructs, discover)
 }
 
-func mergeConfFiles(builtInConfPath, userConfPath, mergedConfPath, platform string, builtInConfStructs map[string]*UnifiedConfig) error {
+func mergeConfFiles(builtInConfPath, userConfPath, discoveredConfPath, mergedConfPath, platform string, builtInConfStructs map[string]*UnifiedConfig, discover Discoverer) error {
 	builtInStruct := builtInConfStructs[platform]
 	builtInYaml, err := yaml.Marshal(builtInStruct)
 	if err != nil {
@@ -61,6 +69,12 @@ func mergeConfFiles(builtInConfPath, userConfPath, mergedConfPath, platform stri
 		if err != nil {
 			return err
 		}
+		if overrides.AutoDiscover && discover != nil {
+			if err := mergeDiscoveredConfig(&original, &overrides, discoveredConfPath, discover); err != nil {
+				// Discovery is best effort: the agent still runs with the user config if it fails.
+				log.Printf("Skipping auto-discovery, continuing with the user config: %v", err)
+			}
+		}
 		mergeConfigs(&original, &overrides)
 	}
 
@@ -75,7 +89,88 @@ func mergeConfFiles(builtInConfPath, userConfPath, mergedConfPath, platform stri
 	return nil
 }
 
+// mergeDiscoveredConfig merges the config proposed by discover, without the applications configured by the user, into
+// original.
+func mergeDiscoveredConfig(original, user *UnifiedConfig, discoveredConfPath string, discover Discoverer) error {
+	discovered, err := discover()
+	if err != nil {
+		return fmt.Errorf("failed to discover applications: %w", err)
+	}
+	removeConfiguredApps(discovered, user)
+	// Write the discovered conf to disk for debugging purpose.
+	discoveredYaml, err := yaml.Marshal(discovered)
+	if err != nil {
+		return fmt.Errorf("failed to convert the discovered config %q to yaml: %w", discoveredConfPath, err)
+	}
+	if err := writeConfigFile(discoveredYaml, discoveredConfPath); err != nil {
+		return err
+	}
+	mergeConfigs(original, discovered)
+	return nil
+}
+
+// removeConfiguredApps removes the discovered receivers of the types that the user has configured, along with the
+// pipelines that only contained them. The user config is assumed to already describe those applications correctly.
+func removeConfiguredApps(discovered, user *UnifiedConfig) {
+	if user.Logging != nil && discovered.Logging != nil {
+		configured := map[string]bool{}
+		for _, r := range user.Logging.Receivers {
+			configured[r.Type()] = true
+		}
+		for id, r := range discovered.Logging.Receivers {
+			if configured[r.Type()] {
+				delete(discovered.Logging.Receivers, id)
+			}
+		}
+		for name, p := range discovered.Logging.Service.Pipelines {
+			p.ReceiverIDs = existingIDs(p.ReceiverIDs, discovered.Logging.Receivers)
+			if len(p.ReceiverIDs) == 0 {
+				delete(discovered.Logging.Service.Pipelines, name)
+			}
+		}
+	}
+	if user.Metrics != nil && discovered.Metrics != nil {
+		configured := map[string]bool{}
+		for _, r := range user.Metrics.Receivers {
+			configured[r.Type()] = true
+		}
+		for _, t := range jvmReceivers {
+			if configured[t] {
+				// Only one JVM based receiver can be configured, so none of them can be added.
+				for _, t := range jvmReceivers {
+					configured[t] = true
+				}
+				break
+			}
+		}
+		for id, r := range discovered.Metrics.Receivers {
+			if configured[r.Type()] {
+				delete(discovered.Metrics.Receivers, id)
+			}
+		}
+		for name, p := range discovered.Metrics.Service.Pipelines {
+			p.ReceiverIDs = existingIDs(p.ReceiverIDs, discovered.Metrics.Receivers)
+			if len(p.ReceiverIDs) == 0 {
+				delete(discovered.Metrics.Service.Pipelines, name)
+			}
+		}
+	}
+}
+
+// existingIDs returns the ids that are keys of components.
+func existingIDs(ids []string, components interface{}) []string {
+	keys := mapKeys(components)
+	var out []string
+	for _, id := range ids {
+		if keys[id] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
 func mergeConfigs(original, overrides *UnifiedConfig) {
+	original.AutoDiscover = overrides.AutoDiscover
 	// For "default_pipeline", we go one level deeper.
 	// this covers 2 cases:
 	// 1. if "<receivers / processors / exporters>: []" is specified explicitly in user config, the entity gets cleared.
@@ -103,6 +198,14 @@ func mergeConfigs(original, overrides *UnifiedConfig) {
 			if overrides.Logging.Service.LogLevel != "info" {
 				original.Logging.Service.LogLevel = overrides.Logging.Service.LogLevel
 			}
+			// Overrides logging.service.settings.
+			if overrides.Logging.Service.Settings != nil {
+				original.Logging.Service.Settings = overrides.Logging.Service.Settings
+			}
+			// Overrides logging.service.output.
+			if overrides.Logging.Service.Output != nil {
+				original.Logging.Service.Output = overrides.Logging.Service.Output
+			}
 			for name, pipeline := range overrides.Logging.Service.Pipelines {
 				// skips logging.service.pipelines.*.exporters
 				pipeline.ExporterIDs = nil
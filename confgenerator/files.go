@@ -56,6 +56,11 @@ func GenerateFilesFromConfig(uc *UnifiedConfig, service, logsDir, stateDir, outD
 	case "": // Validate-only.
 		return nil
 	case "fluentbit":
+		// Read the Lua scripts first, so that no config is written if one of them is missing.
+		scripts, err := uc.Logging.luaScripts()
+		if err != nil {
+			return fmt.Errorf("can't parse configuration: %w", err)
+		}
 		mainConfig, parserConfig, err := uc.GenerateFluentBitConfigs(logsDir, stateDir, hostInfo)
 		if err != nil {
 			return fmt.Errorf("can't parse configuration: %w", err)
@@ -66,6 +71,11 @@ func GenerateFilesFromConfig(uc *UnifiedConfig, service, logsDir, stateDir, outD
 		if err = writeConfigFile([]byte(parserConfig), filepath.Join(outDir, "fluent_bit_parser.conf")); err != nil {
 			return err
 		}
+		for name, script := range scripts {
+			if err = writeConfigFile([]byte(script), filepath.Join(outDir, name)); err != nil {
+				return err
+			}
+		}
 	case "otel":
 		otelConfig, err := uc.GenerateOtelConfig(hostInfo)
 		if err != nil {
@@ -29,8 +29,8 @@ func TranslationComponents(tag, src, dest string, translations []struct{ SrcVal,
 			Config: map[string]string{
 				"Name":      "modify",
 				"Match":     tag,
-				"Condition": fmt.Sprintf("Key_Value_Equals %s %s", src, t.SrcVal),
-				"Add":       fmt.Sprintf("%s %s", dest, t.DestVal),
+				"Condition": fmt.Sprintf("Key_Value_Equals %s %s", ModifyQuote(src), ModifyQuote(t.SrcVal)),
+				"Add":       fmt.Sprintf("%s %s", ModifyQuote(dest), ModifyQuote(t.DestVal)),
 			},
 		})
 	}
@@ -38,6 +38,16 @@ func TranslationComponents(tag, src, dest string, translations []struct{ SrcVal,
 	return c
 }
 
+// ModifyQuote returns s as a single argument of a modify filter rule or condition.
+// The modify filter splits its arguments on whitespace unless they are double-quoted, and has no way to escape
+// double quotes, so s must not contain any.
+func ModifyQuote(s string) string {
+	if strings.ContainsAny(s, " \t") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
 // The parser component is incomplete and needs (at a minimum) the "Format" key to be set.
 func ParserComponentBase(TimeFormat string, TimeKey string, Types map[string]string, tag string, uid string) (Component, string) {
 	parserName := fmt.Sprintf("%s.%s", tag, uid)
@@ -86,3 +96,51 @@ func ParserFilterComponent(tag string, field string, parserNames []string) Compo
 
 	return filter
 }
+
+// LuaFilterComponent returns a filter that runs the Lua function call, defined by the inline Lua code, on each record.
+// code must fit on a single line.
+func LuaFilterComponent(tag, call, code string) Component {
+	return Component{
+		Kind: "FILTER",
+		Config: map[string]string{
+			"Name":  "lua",
+			"Match": tag,
+			"call":  call,
+			"code":  code,
+		},
+	}
+}
+
+// LuaQuote returns s as a double-quoted Lua string literal.
+func LuaQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// LuaCode joins the lines of a Lua program into a single line, as required by the "code" property of the Lua filter.
+// The program must not contain comments or multi-line strings.
+func LuaCode(lines ...string) string {
+	var out []string
+	for _, l := range lines {
+		for _, part := range strings.Split(l, "\n") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return strings.Join(out, " ")
+}
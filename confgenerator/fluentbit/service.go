@@ -17,6 +17,23 @@ package fluentbit
 type Service struct {
 	// Allowed log levels are: error, warn, info, debug, and trace.
 	LogLevel string
+	// Flush is the flush interval in seconds, which can be fractional. It defaults to "1".
+	Flush string
+	// HTTPListen and HTTPPort are the address of the built-in HTTP server. They default to "0.0.0.0" and "2020".
+	HTTPListen string
+	HTTPPort   string
+	// BacklogMemLimit defaults to "50M".
+	BacklogMemLimit string
+	// MaxChunksUp defaults to "128".
+	MaxChunksUp string
+}
+
+// orDefault returns value, or def if value is empty.
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
 }
 
 func (s Service) Component() Component {
@@ -25,7 +42,7 @@ func (s Service) Component() Component {
 		Config: map[string]string{
 			// https://docs.fluentbit.io/manual/administration/configuring-fluent-bit/configuration-file#config_section
 			// Flush logs every 1 second, even if the buffer is not full to minimize log entry arrival delay.
-			"Flush": "1",
+			"Flush": orDefault(s.Flush, "1"),
 			// We use systemd to manage Fluent Bit instead.
 			"Daemon": "off",
 			// Log_File is set by Fluent Bit systemd unit (e.g. /var/log/google-cloud-ops-agent/subagents/logging-module.log).
@@ -34,22 +51,24 @@ func (s Service) Component() Component {
 			// https://docs.fluentbit.io/manual/administration/monitoring
 			// Enable a built-in HTTP server that can be used to query internal information and monitor metrics of each running plugin.
 			"HTTP_Server": "On",
-			"HTTP_Listen": "0.0.0.0",
-			"HTTP_PORT":   "2020",
+			"HTTP_Listen": orDefault(s.HTTPListen, "0.0.0.0"),
+			"HTTP_PORT":   orDefault(s.HTTPPort, "2020"),
 
 			// https://docs.fluentbit.io/manual/administration/buffering-and-storage#service-section-configuration
 			// storage.path is set by Fluent Bit systemd unit (e.g. /var/lib/google-cloud-ops-agent/fluent-bit/buffers).
 			"storage.sync": "normal",
 			// Enable the data integrity check when writing and reading data from the filesystem.
+			// It is not part of the service settings: without it, the chunks corrupted by a crash or a full disk would be
+			// loaded from the backlog and sent as is, and the CRC32 of a chunk is cheap next to sending it.
 			"storage.checksum": "on",
 			// The maximum amount of data to load into the memory when processing old chunks from the backlog that is from previous Fluent Bit processes (e.g. Fluent Bit may have crashed or restarted).
-			"storage.backlog.mem_limit": "50M",
+			"storage.backlog.mem_limit": orDefault(s.BacklogMemLimit, "50M"),
 			// Enable storage metrics in the built-in HTTP server.
 			"storage.metrics": "on",
 			// This is exclusive to filesystem storage type. It specifies the number of chunks (every chunk is a file) that can be up in memory.
 			// Every chunk is a file, so having it up in memory means having an open file descriptor. In case there are thousands of chunks,
 			// we don't want them to all be loaded into the memory.
-			"storage.max_chunks_up": "128",
+			"storage.max_chunks_up": orDefault(s.MaxChunksUp, "128"),
 		},
 	}
 }
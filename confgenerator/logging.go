@@ -16,51 +16,128 @@ package confgenerator
 
 import (
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/fluentbit"
 )
 
-// setLogNameComponents generates a series of components that rewrites the tag on log entries tagged `tag` to be `logName`.
-func setLogNameComponents(tag, logName string) []fluentbit.Component {
-	// TODO: Can we just set log_name_key in the output plugin and avoid this mess?
-	return []fluentbit.Component{
-		{
+// logNameKey is the field holding the log name of the records, see log_name_key in stackdriverOutputComponent.
+// The tag of the records is used as their log name when the field is missing.
+const logNameKey = "logging.googleapis.com/logName"
+
+// A logNamer is a logging receiver with a configurable log name.
+type logNamer interface {
+	logName() string
+}
+
+// A postProcessor is a logging receiver with components that run after the processors of the pipeline, such as the
+// ones that move the fields it adds to labels.
+// The parser filters of the processors keep the other fields of the records, so that these fields are not lost when
+// the records are parsed.
+type postProcessor interface {
+	postProcessorComponents(tag string) []fluentbit.Component
+}
+
+// reserveData makes the parser filters in components keep the fields of the records that they don't parse.
+func reserveData(components []fluentbit.Component) {
+	for _, c := range components {
+		if c.Kind == "FILTER" && c.Config["Name"] == "parser" {
+			c.Config["Reserve_Data"] = "On"
+		}
+	}
+}
+
+// setLogNameComponents generates the components that set the logNameKey field of log entries tagged `tag` to `logName`.
+// Each "${field}" in logName is replaced by the value of field in the entry, and `fallback` is used if any of them is missing.
+func setLogNameComponents(tag, logName, fallback string) []fluentbit.Component {
+	if !strings.Contains(logName, "${") {
+		return []fluentbit.Component{{
 			Kind: "FILTER",
 			Config: map[string]string{
 				"Match": tag,
-				"Add":   fmt.Sprintf("logName %s", logName),
 				"Name":  "modify",
+				"Set":   fmt.Sprintf("%s %s", logNameKey, logName),
 			},
-		},
-		{
-			Kind: "FILTER",
-			Config: map[string]string{
-				"Emitter_Mem_Buf_Limit": "10M",
-				"Emitter_Storage.type":  "filesystem",
-				"Match":                 tag,
-				"Name":                  "rewrite_tag",
-				"Rule":                  "$logName .* $logName false",
-			},
-		},
-		{
-			Kind: "FILTER",
-			Config: map[string]string{
-				"Match":  logName,
-				"Name":   "modify",
-				"Remove": "logName",
-			},
-		},
+		}}
+	}
+	code := fluentbit.LuaCode(
+		fmt.Sprintf("local template = %s", fluentbit.LuaQuote(logName)),
+		fmt.Sprintf("local fallback = %s", fluentbit.LuaQuote(fallback)),
+		fmt.Sprintf("local key = %s", fluentbit.LuaQuote(logNameKey)),
+		`function log_name(tag, timestamp, record)
+		  local missing = false
+		  local name = string.gsub(template, "%${([^}]+)}", function(field)
+		    local value = record[field]
+		    if value == nil or type(value) == "table" then
+		      missing = true
+		      return ""
+		    end
+		    return (string.gsub(tostring(value), "[^%w_./-]", "_"))
+		  end)
+		  if missing then name = fallback end
+		  record[key] = name
+		  return 2, timestamp, record
+		end`,
+	)
+	return []fluentbit.Component{fluentbit.LuaFilterComponent(tag, "log_name", code)}
+}
+
+// defaultMonitoringListenAddress is the default address of the built-in HTTP server of Fluent Bit.
+const defaultMonitoringListenAddress = "0.0.0.0:2020"
+
+// monitoringAddress returns the address of the built-in HTTP server of Fluent Bit.
+func (l *Logging) monitoringAddress() string {
+	if l == nil || l.Service == nil || l.Service.Settings == nil || l.Service.Settings.MonitoringListenAddress == "" {
+		return defaultMonitoringListenAddress
+	}
+	return l.Service.Settings.MonitoringListenAddress
+}
+
+// monitoringScrapeAddress returns the address to scrape the built-in HTTP server of Fluent Bit at. The server is
+// scraped through the loopback interface when it listens on all of them.
+func (l *Logging) monitoringScrapeAddress() string {
+	host, port, _ := net.SplitHostPort(l.monitoringAddress())
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "127.0.0.1"
 	}
+	return net.JoinHostPort(host, port)
 }
 
-// stackdriverOutputComponent generates a component that outputs logs matching the regex `match` using `userAgent`.
-func stackdriverOutputComponent(match, userAgent string) fluentbit.Component {
-	return fluentbit.Component{
+// serviceComponent generates the service section of Fluent Bit, with the settings of l.
+func (l *Logging) serviceComponent() fluentbit.Component {
+	host, port, _ := net.SplitHostPort(l.monitoringAddress())
+	service := fluentbit.Service{
+		LogLevel:   l.Service.LogLevel,
+		HTTPListen: host,
+		HTTPPort:   port,
+	}
+	if settings := l.Service.Settings; settings != nil {
+		if settings.FlushInterval != "" {
+			// Unlike the other intervals, the flush interval doesn't have to be a whole number of seconds.
+			t, _ := time.ParseDuration(settings.FlushInterval)
+			service.Flush = strconv.FormatFloat(t.Seconds(), 'f', -1, 64)
+		}
+		service.BacklogMemLimit = settings.BacklogMemLimit
+		if settings.MaxChunksUp > 0 {
+			service.MaxChunksUp = strconv.Itoa(settings.MaxChunksUp)
+		}
+	}
+	return service.Component()
+}
+
+// stackdriverOutputComponent generates a component that outputs logs with tags matching the pattern `match` using `userAgent`.
+// The output and its buffer are configured by the settings of `service`, if any.
+func stackdriverOutputComponent(match, userAgent string, service *LoggingService) fluentbit.Component {
+	output := fluentbit.Component{
 		Kind: "OUTPUT",
 		Config: map[string]string{
 			// https://docs.fluentbit.io/manual/pipeline/outputs/stackdriver
 			"Name":              "stackdriver",
-			"Match_Regex":       fmt.Sprintf("^(%s)$", match),
+			"Match":             match,
+			"log_name_key":      logNameKey,
 			"resource":          "gce_instance",
 			"stackdriver_agent": userAgent,
 
@@ -71,10 +148,49 @@ func stackdriverOutputComponent(match, userAgent string) fluentbit.Component {
 			// https://docs.fluentbit.io/manual/administration/security
 			// Enable TLS support.
 			"tls": "On",
-			// Do not force certificate validation.
-			"tls.verify": "Off",
+			// Verify the certificate of the Cloud Logging API, unless disabled in the output settings.
+			"tls.verify": "On",
 
 			"workers": "8",
 		},
 	}
+	if service == nil {
+		return output
+	}
+	if s := service.Settings; s != nil && s.BufferLimit != "" {
+		// https://docs.fluentbit.io/manual/administration/buffering-and-storage#output-section-configuration
+		output.Config["storage.total_limit_size"] = s.BufferLimit
+	}
+	if o := service.Output; o != nil {
+		switch o.RetryLimit {
+		case "":
+		case "unlimited":
+			output.Config["Retry_Limit"] = "no_limits"
+		default:
+			output.Config["Retry_Limit"] = o.RetryLimit
+		}
+		if o.Workers > 0 {
+			output.Config["workers"] = strconv.Itoa(o.Workers)
+		}
+		if o.TLSVerify != nil && !*o.TLSVerify {
+			output.Config["tls.verify"] = "Off"
+		}
+		if o.TLSCAFile != "" {
+			output.Config["tls.ca_file"] = o.TLSCAFile
+		}
+		if o.Compress != "" {
+			output.Config["compress"] = o.Compress
+		}
+		if o.ResourceType != "" {
+			output.Config["resource"] = o.ResourceType
+		}
+		if len(o.ResourceLabels) > 0 {
+			var labels []string
+			for _, k := range sortedKeys(o.ResourceLabels) {
+				labels = append(labels, fmt.Sprintf("%s=%s", k, o.ResourceLabels[k]))
+			}
+			output.Config["resource_labels"] = strings.Join(labels, ",")
+		}
+	}
+	return output
 }
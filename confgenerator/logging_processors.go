@@ -15,7 +15,12 @@
 package confgenerator
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/fluentbit"
@@ -26,10 +31,9 @@ type ParserShared struct {
 	TimeKey    string `yaml:"time_key,omitempty"`    // by default does not parse timestamp
 	TimeFormat string `yaml:"time_format,omitempty"` // must be provided if time_key is present
 	// Types allows parsing the extracted fields.
-	// Not exposed to users for now, but can be used by app receivers.
 	// Documented at https://docs.fluentbit.io/manual/v/1.3/parser
 	// According to docs, this is only supported with `ltsv`, `logfmt`, and `regex` parsers.
-	Types map[string]string `yaml:"-" validate:"dive,oneof=string integer bool float hex"`
+	Types map[string]string `yaml:"types,omitempty" validate:"dive,oneof=string integer bool float hex"`
 }
 
 func (p ParserShared) Component(tag, uid string) (fluentbit.Component, string) {
@@ -60,6 +64,210 @@ func init() {
 	LoggingProcessorTypes.RegisterType(func() Component { return &LoggingProcessorParseJson{} })
 }
 
+// A LoggingProcessorParseLogfmt parses the specified field as logfmt, e.g. `level=info msg="hello world"`.
+type LoggingProcessorParseLogfmt struct {
+	ConfigComponent `yaml:",inline"`
+	ParserShared    `yaml:",inline"`
+	Field           string `yaml:"field,omitempty"`
+}
+
+func (r LoggingProcessorParseLogfmt) Type() string {
+	return "parse_logfmt"
+}
+
+func (p LoggingProcessorParseLogfmt) Components(tag, uid string) []fluentbit.Component {
+	parser, parserName := p.ParserShared.Component(tag, uid)
+	parser.Config["Format"] = "logfmt"
+	return []fluentbit.Component{
+		fluentbit.ParserFilterComponent(tag, p.Field, []string{parserName}),
+		parser,
+	}
+}
+
+func init() {
+	LoggingProcessorTypes.RegisterType(func() Component { return &LoggingProcessorParseLogfmt{} })
+}
+
+// A LoggingProcessorParseLtsv parses the specified field as LTSV, e.g. "host:127.0.0.1<TAB>status:200".
+type LoggingProcessorParseLtsv struct {
+	ConfigComponent `yaml:",inline"`
+	ParserShared    `yaml:",inline"`
+	Field           string `yaml:"field,omitempty"`
+}
+
+func (r LoggingProcessorParseLtsv) Type() string {
+	return "parse_ltsv"
+}
+
+func (p LoggingProcessorParseLtsv) Components(tag, uid string) []fluentbit.Component {
+	parser, parserName := p.ParserShared.Component(tag, uid)
+	parser.Config["Format"] = "ltsv"
+	return []fluentbit.Component{
+		fluentbit.ParserFilterComponent(tag, p.Field, []string{parserName}),
+		parser,
+	}
+}
+
+func init() {
+	LoggingProcessorTypes.RegisterType(func() Component { return &LoggingProcessorParseLtsv{} })
+}
+
+// A LoggingProcessorParseDelimited parses the specified field as delimiter-separated values, such as CSV or TSV,
+// storing each value under the name of its column. Quoted values are not supported, so values cannot contain the delimiter.
+type LoggingProcessorParseDelimited struct {
+	ConfigComponent `yaml:",inline"`
+	ParserShared    `yaml:",inline"`
+	Field           string `yaml:"field,omitempty"`
+	// Delimiter is the single character separating the values, or `\t` for tabs. It defaults to ",".
+	Delimiter string `yaml:"delimiter,omitempty" validate:"omitempty,delimiter"`
+	// Columns are the names of the values, in order.
+	Columns []string `yaml:"columns" validate:"required,dive,required,fieldname"`
+}
+
+func (r LoggingProcessorParseDelimited) Type() string {
+	return "parse_delimited"
+}
+
+// delimitedRegex returns a regex with one named capture group per column.
+func delimitedRegex(delimiter string, columns []string) string {
+	var d string
+	switch delimiter {
+	case "\t":
+		d = `\t`
+	case "-", "]", "^", `\`:
+		d = `\` + delimiter
+	default:
+		d = regexp.QuoteMeta(delimiter)
+	}
+	var groups []string
+	for _, c := range columns {
+		groups = append(groups, fmt.Sprintf("(?<%s>[^%s]*)", c, d))
+	}
+	return "^" + strings.Join(groups, d) + "$"
+}
+
+func (p LoggingProcessorParseDelimited) Components(tag, uid string) []fluentbit.Component {
+	delimiter := p.Delimiter
+	switch delimiter {
+	case "":
+		delimiter = ","
+	case `\t`:
+		delimiter = "\t"
+	}
+	parser, parserName := p.ParserShared.Component(tag, uid)
+	parser.Config["Format"] = "regex"
+	parser.Config["Regex"] = delimitedRegex(delimiter, p.Columns)
+	return []fluentbit.Component{
+		parser,
+		fluentbit.ParserFilterComponent(tag, p.Field, []string{parserName}),
+	}
+}
+
+func init() {
+	LoggingProcessorTypes.RegisterType(func() Component { return &LoggingProcessorParseDelimited{} })
+}
+
+// A LoggingProcessorParseKeyValue parses the specified field as key/value pairs with configurable delimiters,
+// e.g. "user:alice;status:200".
+// The pairs are first rewritten as logfmt by a Lua filter, so that they can be parsed by a logfmt parser.
+type LoggingProcessorParseKeyValue struct {
+	ConfigComponent `yaml:",inline"`
+	ParserShared    `yaml:",inline"`
+	Field           string `yaml:"field,omitempty"`
+	// FieldDelimiter separates the pairs. It defaults to " ".
+	FieldDelimiter string `yaml:"field_delimiter,omitempty" validate:"omitempty,excludes=\",key_value_delimiters"`
+	// ValueDelimiter separates the key from the value in each pair. It defaults to "=".
+	// The delimiters can't overlap, since a pair couldn't be split unambiguously otherwise.
+	ValueDelimiter string `yaml:"value_delimiter,omitempty" validate:"omitempty,excludes=\",key_value_delimiters"`
+}
+
+func (r LoggingProcessorParseKeyValue) Type() string {
+	return "parse_key_value"
+}
+
+func (p LoggingProcessorParseKeyValue) fieldDelimiter() string {
+	if p.FieldDelimiter == "" {
+		return " "
+	}
+	return p.FieldDelimiter
+}
+
+func (p LoggingProcessorParseKeyValue) valueDelimiter() string {
+	if p.ValueDelimiter == "" {
+		return "="
+	}
+	return p.ValueDelimiter
+}
+
+// delimitersOverlap reports whether a and b are equal, one contains the other, or a suffix of one is a prefix of the
+// other.
+func delimitersOverlap(a, b string) bool {
+	if strings.Contains(a, b) || strings.Contains(b, a) {
+		return true
+	}
+	for i := 1; i < len(a); i++ {
+		if strings.HasPrefix(b, a[i:]) {
+			return true
+		}
+	}
+	for i := 1; i < len(b); i++ {
+		if strings.HasPrefix(a, b[i:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p LoggingProcessorParseKeyValue) Components(tag, uid string) []fluentbit.Component {
+	field := p.Field
+	if field == "" {
+		field = "message"
+	}
+	code := fluentbit.LuaCode(
+		fmt.Sprintf("local field = %s", fluentbit.LuaQuote(field)),
+		fmt.Sprintf("local field_delimiter = %s", fluentbit.LuaQuote(p.fieldDelimiter())),
+		fmt.Sprintf("local value_delimiter = %s", fluentbit.LuaQuote(p.valueDelimiter())),
+		`function key_value(tag, timestamp, record)
+		  local s = record[field]
+		  if type(s) ~= "string" then return 0, timestamp, record end
+		  local out = {}
+		  local start = 1
+		  while start <= #s + 1 do
+		    local item
+		    local i, j = string.find(s, field_delimiter, start, true)
+		    if i then
+		      item = string.sub(s, start, i - 1)
+		      start = j + 1
+		    else
+		      item = string.sub(s, start)
+		      start = #s + 2
+		    end
+		    local k, l = string.find(item, value_delimiter, 1, true)
+		    if k and k > 1 then
+		      local key = string.sub(item, 1, k - 1)
+		      if not string.find(key, '[%s="]') then
+		        local value = string.gsub(string.sub(item, l + 1), '["\\]', '\\%0')
+		        table.insert(out, key .. '="' .. value .. '"')
+		      end
+		    end
+		  end
+		  record[field] = table.concat(out, " ")
+		  return 2, timestamp, record
+		end`,
+	)
+	parser, parserName := p.ParserShared.Component(tag, uid)
+	parser.Config["Format"] = "logfmt"
+	return []fluentbit.Component{
+		fluentbit.LuaFilterComponent(tag, "key_value", code),
+		fluentbit.ParserFilterComponent(tag, p.Field, []string{parserName}),
+		parser,
+	}
+}
+
+func init() {
+	LoggingProcessorTypes.RegisterType(func() Component { return &LoggingProcessorParseKeyValue{} })
+}
+
 // A LoggingProcessorParseRegex applies a regex to the specified field, storing the named capture groups as keys in the log record.
 // This was maintained in addition to the parse_regex_complex to ensure backward compatibility with any existing configurations
 type LoggingProcessorParseRegex struct {
@@ -113,9 +321,33 @@ func (p LoggingProcessorParseRegexComplex) Components(tag, uid string) []fluentb
 }
 
 type MultilineRule struct {
-	StateName string
-	Regex     string
-	NextState string
+	StateName string `yaml:"state_name" validate:"required"`
+	Regex     string `yaml:"regex" validate:"required"`
+	NextState string `yaml:"next_state" validate:"required"`
+}
+
+// multilineStartState reports whether one of the rules is for the "start_state" state.
+func multilineStartState(rules []MultilineRule) bool {
+	for _, r := range rules {
+		if r.StateName == "start_state" {
+			return true
+		}
+	}
+	return false
+}
+
+// multilineNextStates reports whether every next_state of the rules has rules of its own.
+func multilineNextStates(rules []MultilineRule) bool {
+	states := map[string]bool{}
+	for _, r := range rules {
+		states[r.StateName] = true
+	}
+	for _, r := range rules {
+		if !states[r.NextState] {
+			return false
+		}
+	}
+	return true
 }
 
 func (r MultilineRule) AsString() string {
@@ -123,7 +355,9 @@ func (r MultilineRule) AsString() string {
 	return fmt.Sprintf(`"%s"    "%s"    "%s"`, r.StateName, escapedRegex, r.NextState)
 }
 
-// A LoggingProcessorParseMultiline applies a set of regex rules to the specified lines, storing the named capture groups as keys in the log record.
+// A LoggingProcessorParseMultilineRegex joins the lines of the specified field into a single record using the multiline Rules,
+// then parses the joined record with the regexes of LoggingProcessorParseRegexComplex.
+// The Rules are rendered into a Fluent Bit multiline parser:
 //     #
 //     # Regex rules for multiline parsing
 //     # ---------------------------------
@@ -179,6 +413,973 @@ func init() {
 	LoggingProcessorTypes.RegisterType(func() Component { return &LoggingProcessorParseRegex{} })
 }
 
+// multilinePresets are the rules of the built-in multiline parsers, by language.
+var multilinePresets = map[string][]MultilineRule{
+	// Exceptions and their causes, e.g.
+	// java.lang.RuntimeException: boom
+	//     at com.example.Main.main(Main.java:5)
+	// Caused by: java.io.IOException: bad
+	//     ... 1 more
+	"java": {
+		{StateName: "start_state", Regex: `^[^\s]`, NextState: "java"},
+		{StateName: "java", Regex: `^(?:\s+at |\s+\.\.\. \d+ (?:more|common frames omitted)|\s*Caused by: |\s*Suppressed: )`, NextState: "java"},
+	},
+	// Tracebacks, e.g.
+	// Traceback (most recent call last):
+	//   File "main.py", line 1, in <module>
+	//     main()
+	// ValueError: boom
+	"python": {
+		{StateName: "start_state", Regex: `^Traceback \(most recent call last\):$`, NextState: "python"},
+		{StateName: "python", Regex: `^[\t ]+`, NextState: "python"},
+		{StateName: "python", Regex: `^[\w.]+(?::|$)`, NextState: "python_end"},
+		{StateName: "python_end", Regex: `^[\t ]+`, NextState: "python_end"},
+	},
+	// Panics, e.g.
+	// panic: boom
+	//
+	// goroutine 1 [running]:
+	// main.main()
+	// 	/src/main.go:5 +0x27
+	"go": {
+		{StateName: "start_state", Regex: `^(?:panic: |fatal error: )`, NextState: "go"},
+		{StateName: "go", Regex: `^(?:$|goroutine \d+ \[|[^\s].*\(.*\)$|\t|created by |\[signal )`, NextState: "go"},
+	},
+	// Backtraces, e.g.
+	// main.rb:3:in `foo': boom (RuntimeError)
+	// 	from main.rb:7:in `<main>'
+	"ruby": {
+		{StateName: "start_state", Regex: `^[^\s]`, NextState: "ruby"},
+		{StateName: "ruby", Regex: `^(?:\s+from |\s+[^\s]+:\d+:in )`, NextState: "ruby"},
+	},
+}
+
+// A LoggingProcessorParseMultiline joins the lines of multi-line records, such as stack traces, into single records.
+type LoggingProcessorParseMultiline struct {
+	ConfigComponent `yaml:",inline"`
+	// Field holds the lines to join. It defaults to "message".
+	Field string `yaml:"field,omitempty"`
+	// Preset is the language of built-in rules to use.
+	Preset string `yaml:"preset,omitempty" validate:"required_without=Rules,omitempty,oneof=go java python ruby"`
+	// Rules are user-defined rules, in the format of LoggingProcessorParseMultilineRegex.
+	Rules []MultilineRule `yaml:"rules,omitempty" validate:"omitempty,multiline_start_state,multiline_next_states,dive"`
+}
+
+func (p LoggingProcessorParseMultiline) Type() string {
+	return "parse_multiline"
+}
+
+func (p LoggingProcessorParseMultiline) Components(tag, uid string) []fluentbit.Component {
+	var components []fluentbit.Component
+	var parserNames []string
+	addParser := func(name string, rules []MultilineRule) {
+		var lines [][2]string
+		for _, rule := range rules {
+			lines = append(lines, [2]string{"rule", rule.AsString()})
+		}
+		components = append(components, fluentbit.Component{
+			Kind: "MULTILINE_PARSER",
+			Config: map[string]string{
+				"Name": name,
+				"Type": "regex",
+			},
+			OrderedConfig: lines,
+		})
+		parserNames = append(parserNames, name)
+	}
+	if p.Preset != "" {
+		addParser(fmt.Sprintf("%s.%s.multiline.%s", tag, uid, p.Preset), multilinePresets[p.Preset])
+	}
+	if len(p.Rules) > 0 {
+		addParser(fmt.Sprintf("%s.%s.multiline", tag, uid), p.Rules)
+	}
+	field := p.Field
+	if field == "" {
+		field = "message"
+	}
+	filter := fluentbit.Component{
+		Kind: "FILTER",
+		Config: map[string]string{
+			"Name":                  "multiline",
+			"Match":                 tag,
+			"Multiline.Key_Content": field,
+			"Multiline.Parser":      strings.Join(parserNames, ","),
+		},
+	}
+	return append([]fluentbit.Component{filter}, components...)
+}
+
+func init() {
+	LoggingProcessorTypes.RegisterType(func() Component { return &LoggingProcessorParseMultiline{} })
+}
+
+// A ModifyFieldsOperation is a single change to the fields of a log record.
+type ModifyFieldsOperation struct {
+	// Action is one of:
+	//   add: sets field to value, unless it is already present.
+	//   set: sets field to value, overwriting it if it is already present.
+	//   remove: removes field.
+	//   rename: renames field to to, unless to is already present.
+	//   copy: copies field to to, unless to is already present.
+	//   move_to_label: moves field to the label named to (or field) in logging.googleapis.com/labels.
+	Action string `yaml:"action" validate:"required,oneof=add set remove rename copy move_to_label"`
+	Field  string `yaml:"field" validate:"required,modify_arg"`
+	Value  string `yaml:"value,omitempty" validate:"required_if_oneof=Action add set,modify_arg"`
+	To     string `yaml:"to,omitempty" validate:"required_if_oneof=Action rename copy,modify_arg"`
+}
+
+// A LoggingProcessorModifyFields applies a list of operations to the fields of each record, in order.
+type LoggingProcessorModifyFields struct {
+	ConfigComponent `yaml:",inline"`
+	Operations      []ModifyFieldsOperation `yaml:"operations" validate:"required,dive"`
+}
+
+func (p LoggingProcessorModifyFields) Type() string {
+	return "modify_fields"
+}
+
+// labelPrefix is temporarily prepended to the fields that are moved to labels.
+const labelPrefix = "__label__"
+
+// nestLabelsComponents returns the filters that move the fields starting with labelPrefix to logging.googleapis.com/labels.
+func nestLabelsComponents(tag string) []fluentbit.Component {
+	// Lift the existing labels next to the new ones, and nest all of them back together.
+	return []fluentbit.Component{
+		{
+			Kind: "FILTER",
+			Config: map[string]string{
+				"Name":         "nest",
+				"Match":        tag,
+				"Operation":    "lift",
+				"Nested_under": "logging.googleapis.com/labels",
+				"Add_prefix":   labelPrefix,
+			},
+		},
+		{
+			Kind: "FILTER",
+			Config: map[string]string{
+				"Name":          "nest",
+				"Match":         tag,
+				"Operation":     "nest",
+				"Wildcard":      labelPrefix + "*",
+				"Nest_under":    "logging.googleapis.com/labels",
+				"Remove_prefix": labelPrefix,
+			},
+		},
+	}
+}
+
+func (p LoggingProcessorModifyFields) Components(tag, uid string) []fluentbit.Component {
+	var rules [][2]string
+	hasLabels := false
+	for _, op := range p.Operations {
+		switch op.Action {
+		case "add":
+			rules = append(rules, [2]string{"Add", fmt.Sprintf("%s %s", fluentbit.ModifyQuote(op.Field), fluentbit.ModifyQuote(op.Value))})
+		case "set":
+			rules = append(rules, [2]string{"Set", fmt.Sprintf("%s %s", fluentbit.ModifyQuote(op.Field), fluentbit.ModifyQuote(op.Value))})
+		case "remove":
+			rules = append(rules, [2]string{"Remove", fluentbit.ModifyQuote(op.Field)})
+		case "rename":
+			rules = append(rules, [2]string{"Rename", fmt.Sprintf("%s %s", fluentbit.ModifyQuote(op.Field), fluentbit.ModifyQuote(op.To))})
+		case "copy":
+			rules = append(rules, [2]string{"Copy", fmt.Sprintf("%s %s", fluentbit.ModifyQuote(op.Field), fluentbit.ModifyQuote(op.To))})
+		case "move_to_label":
+			label := op.To
+			if label == "" {
+				label = op.Field
+			}
+			// Fields are gathered under the labels map once all the operations have been applied.
+			rules = append(rules, [2]string{"Hard_rename", fmt.Sprintf("%s %s", fluentbit.ModifyQuote(op.Field), fluentbit.ModifyQuote(labelPrefix+label))})
+			hasLabels = true
+		}
+	}
+	components := []fluentbit.Component{{
+		Kind: "FILTER",
+		Config: map[string]string{
+			"Name":  "modify",
+			"Match": tag,
+		},
+		OrderedConfig: rules,
+	}}
+	if hasLabels {
+		components = append(components, nestLabelsComponents(tag)...)
+	}
+	return components
+}
+
+func init() {
+	LoggingProcessorTypes.RegisterType(func() Component { return &LoggingProcessorModifyFields{} })
+}
+
+// logSeverities are the LogEntry severities, from lowest to highest.
+var logSeverities = []string{"DEFAULT", "DEBUG", "INFO", "NOTICE", "WARNING", "ERROR", "CRITICAL", "ALERT", "EMERGENCY"}
+
+// LogMatch holds conditions on the fields of a log record. Fields are top-level keys of the record, such as "message".
+// A record matches if and only if it matches all of the conditions, for both include_logs and exclude_logs.
+type LogMatch struct {
+	// Equals matches records where each field is exactly the given value.
+	Equals map[string]string `yaml:"equals,omitempty" validate:"dive,keys,modify_arg,endkeys"`
+	// Regex matches records where each field matches the given regex.
+	Regex map[string]string `yaml:"regex,omitempty" validate:"dive,keys,modify_arg,endkeys,regexp,modify_arg"`
+	// Exists matches records where each field is present (true) or absent (false).
+	Exists map[string]bool `yaml:"exists,omitempty" validate:"dive,keys,modify_arg,endkeys"`
+	// MinSeverity matches records whose severity is at least the given one.
+	// Records without a "logging.googleapis.com/severity" field never match, so include_logs drops them and exclude_logs keeps them.
+	// At least one condition must be set.
+	MinSeverity string `yaml:"min_severity,omitempty" validate:"required_without_all=Equals Regex Exists,omitempty,oneof=DEFAULT DEBUG INFO NOTICE WARNING ERROR CRITICAL ALERT EMERGENCY"`
+}
+
+// severityRegex returns a regex that matches the severities that are at least m.MinSeverity.
+func (m LogMatch) severityRegex() string {
+	for i, severity := range logSeverities {
+		if severity == m.MinSeverity {
+			return fmt.Sprintf("^(?:%s)$", strings.Join(logSeverities[i:], "|"))
+		}
+	}
+	return ""
+}
+
+// grepRules returns one grep filter rule per condition. A record passes a rule if and only if it matches the condition.
+func (m LogMatch) grepRules() [][2]string {
+	var rules [][2]string
+	for _, field := range sortedKeys(m.Equals) {
+		rules = append(rules, [2]string{"Regex", fmt.Sprintf("%s ^%s$", field, regexp.QuoteMeta(m.Equals[field]))})
+	}
+	for _, field := range sortedKeys(m.Regex) {
+		rules = append(rules, [2]string{"Regex", fmt.Sprintf("%s %s", field, m.Regex[field])})
+	}
+	for _, field := range sortedKeys(m.Exists) {
+		// grep can only match on the fields that are present.
+		kind := "Regex"
+		if !m.Exists[field] {
+			kind = "Exclude"
+		}
+		rules = append(rules, [2]string{kind, fmt.Sprintf("%s .*", field)})
+	}
+	if m.MinSeverity != "" {
+		rules = append(rules, [2]string{"Regex", fmt.Sprintf("logging.googleapis.com/severity %s", m.severityRegex())})
+	}
+	return rules
+}
+
+// modifyConditions returns one modify filter condition per condition. A modify filter only applies its rules if all of
+// its conditions are true.
+func (m LogMatch) modifyConditions() [][2]string {
+	var conditions [][2]string
+	for _, field := range sortedKeys(m.Equals) {
+		// The modify filter can't escape double quotes, so match them by their code point instead.
+		value := strings.ReplaceAll(regexp.QuoteMeta(m.Equals[field]), `"`, `\x22`)
+		conditions = append(conditions, [2]string{"Condition", fmt.Sprintf("Key_Value_Matches %s %s", fluentbit.ModifyQuote(field), fluentbit.ModifyQuote("^"+value+"$"))})
+	}
+	for _, field := range sortedKeys(m.Regex) {
+		conditions = append(conditions, [2]string{"Condition", fmt.Sprintf("Key_Value_Matches %s %s", fluentbit.ModifyQuote(field), fluentbit.ModifyQuote(m.Regex[field]))})
+	}
+	for _, field := range sortedKeys(m.Exists) {
+		kind := "Key_Exists"
+		if !m.Exists[field] {
+			kind = "Key_Does_Not_Exist"
+		}
+		conditions = append(conditions, [2]string{"Condition", fmt.Sprintf("%s %s", kind, fluentbit.ModifyQuote(field))})
+	}
+	if m.MinSeverity != "" {
+		conditions = append(conditions, [2]string{"Condition", fmt.Sprintf("Key_Value_Matches logging.googleapis.com/severity %s", m.severityRegex())})
+	}
+	return conditions
+}
+
+// A LoggingProcessorIncludeLogs keeps only the records that match all of the conditions.
+type LoggingProcessorIncludeLogs struct {
+	ConfigComponent `yaml:",inline"`
+	LogMatch        `yaml:",inline"`
+}
+
+func (p LoggingProcessorIncludeLogs) Type() string {
+	return "include_logs"
+}
+
+func (p LoggingProcessorIncludeLogs) Components(tag, uid string) []fluentbit.Component {
+	rules := p.grepRules()
+	// Rules in a single grep filter must all pass for a record to be kept.
+	return []fluentbit.Component{{
+		Kind: "FILTER",
+		Config: map[string]string{
+			"Name":  "grep",
+			"Match": tag,
+		},
+		OrderedConfig: rules,
+	}}
+}
+
+// A LoggingProcessorExcludeLogs drops the records that match all of the conditions.
+type LoggingProcessorExcludeLogs struct {
+	ConfigComponent `yaml:",inline"`
+	LogMatch        `yaml:",inline"`
+}
+
+func (p LoggingProcessorExcludeLogs) Type() string {
+	return "exclude_logs"
+}
+
+func (p LoggingProcessorExcludeLogs) Components(tag, uid string) []fluentbit.Component {
+	// grep drops a record if it fails any rule, so it can't drop only the records that match all of the conditions.
+	// Instead, mark those records with a modify filter and drop the marked ones.
+	marker := fmt.Sprintf("logging.googleapis.com/%s.%s.excluded", tag, uid)
+	return []fluentbit.Component{
+		{
+			Kind: "FILTER",
+			Config: map[string]string{
+				"Name":  "modify",
+				"Match": tag,
+			},
+			OrderedConfig: append(p.modifyConditions(), [2]string{"Add", fmt.Sprintf("%s true", fluentbit.ModifyQuote(marker))}),
+		},
+		{
+			Kind: "FILTER",
+			Config: map[string]string{
+				"Name":  "grep",
+				"Match": tag,
+			},
+			OrderedConfig: [][2]string{{"Exclude", fmt.Sprintf("%s .*", marker)}},
+		},
+	}
+}
+
+func init() {
+	LoggingProcessorTypes.RegisterType(func() Component { return &LoggingProcessorIncludeLogs{} })
+	LoggingProcessorTypes.RegisterType(func() Component { return &LoggingProcessorExcludeLogs{} })
+}
+
+// defaultSeverityMappings maps the common spellings of log levels, matched case-insensitively, to LogSeverity values.
+var defaultSeverityMappings = []struct{ Regex, Severity string }{
+	{"trace|debug|dbg|fine|finer|finest|verbose", "DEBUG"},
+	{"info|information|informational|inf", "INFO"},
+	{"notice", "NOTICE"},
+	{"warn|warning|wrn", "WARNING"},
+	{"error|err|severe", "ERROR"},
+	{"critical|crit|fatal", "CRITICAL"},
+	{"alert", "ALERT"},
+	{"emergency|emerg|panic", "EMERGENCY"},
+}
+
+// A LoggingProcessorMapSeverity sets the severity of each record from the value of a field.
+type LoggingProcessorMapSeverity struct {
+	ConfigComponent `yaml:",inline"`
+	// Field holds the level of the record. It defaults to "level".
+	Field string `yaml:"field,omitempty" validate:"modify_arg"`
+	// Mappings maps exact field values to LogSeverity values. They take precedence over the default mappings.
+	Mappings map[string]string `yaml:"mappings,omitempty" validate:"dive,keys,modify_arg,endkeys,oneof=DEFAULT DEBUG INFO NOTICE WARNING ERROR CRITICAL ALERT EMERGENCY"`
+}
+
+func (p LoggingProcessorMapSeverity) Type() string {
+	return "map_severity"
+}
+
+func (p LoggingProcessorMapSeverity) Components(tag, uid string) []fluentbit.Component {
+	field := p.Field
+	if field == "" {
+		field = "level"
+	}
+	// Each filter only adds the severity if it is not set yet, so the first matching mapping wins.
+	var translations []struct{ SrcVal, DestVal string }
+	for _, value := range sortedKeys(p.Mappings) {
+		translations = append(translations, struct{ SrcVal, DestVal string }{value, p.Mappings[value]})
+	}
+	c := fluentbit.TranslationComponents(tag, field, "logging.googleapis.com/severity", translations)
+	for _, m := range defaultSeverityMappings {
+		c = append(c, fluentbit.Component{
+			Kind: "FILTER",
+			Config: map[string]string{
+				"Name":      "modify",
+				"Match":     tag,
+				"Condition": fmt.Sprintf("Key_Value_Matches %s ^(?i:%s)$", fluentbit.ModifyQuote(field), m.Regex),
+				"Add":       fmt.Sprintf("logging.googleapis.com/severity %s", m.Severity),
+			},
+		})
+	}
+	return c
+}
+
+func init() {
+	LoggingProcessorTypes.RegisterType(func() Component { return &LoggingProcessorMapSeverity{} })
+}
+
+// redactDetectors are the built-in detectors of the redact processor, as Lua patterns and the name of the Lua
+// function that checks or scans each match, if any. They are applied in this order.
+var redactDetectors = []struct{ Name, Pattern, Check string }{
+	{"token", `eyJ[%w%-_]+%.[%w%-_]+%.[%w%-_]+`, ""},
+	{"token", `[Bb]earer%s+[%w%-%._~%+/]+=*`, ""},
+	{"email", `[%w%.%%%+%-_]+@[%w%.%-]+%.%a%a+`, ""},
+	// Runs of digits, spaces and dashes, which are searched for card numbers.
+	{"credit_card", `%d[%d%- ]+%d`, "cards"},
+	{"ipv4", `%d+%.%d+%.%d+%.%d+`, "ipv4"},
+	{"ipv6", `%x*:[%x:]*%x`, "ipv6"},
+}
+
+// redactHashLua holds a Lua implementation of HMAC-SHA256, since the Lua of fluentbit has no crypto library.
+const redactHashLua = `
+local band, bor, bxor, bnot, lshift, rshift, ror, tobit = bit.band, bit.bor, bit.bxor, bit.bnot, bit.lshift, bit.rshift, bit.ror, bit.tobit
+local K = {
+  0x428a2f98, 0x71374491, 0xb5c0fbcf, 0xe9b5dba5, 0x3956c25b, 0x59f111f1, 0x923f82a4, 0xab1c5ed5,
+  0xd807aa98, 0x12835b01, 0x243185be, 0x550c7dc3, 0x72be5d74, 0x80deb1fe, 0x9bdc06a7, 0xc19bf174,
+  0xe49b69c1, 0xefbe4786, 0x0fc19dc6, 0x240ca1cc, 0x2de92c6f, 0x4a7484aa, 0x5cb0a9dc, 0x76f988da,
+  0x983e5152, 0xa831c66d, 0xb00327c8, 0xbf597fc7, 0xc6e00bf3, 0xd5a79147, 0x06ca6351, 0x14292967,
+  0x27b70a85, 0x2e1b2138, 0x4d2c6dfc, 0x53380d13, 0x650a7354, 0x766a0abb, 0x81c2c92e, 0x92722c85,
+  0xa2bfe8a1, 0xa81a664b, 0xc24b8b70, 0xc76c51a3, 0xd192e819, 0xd6990624, 0xf40e3585, 0x106aa070,
+  0x19a4c116, 0x1e376c08, 0x2748774c, 0x34b0bcb5, 0x391c0cb3, 0x4ed8aa4a, 0x5b9cca4f, 0x682e6ff3,
+  0x748f82ee, 0x78a5636f, 0x84c87814, 0x8cc70208, 0x90befffa, 0xa4506ceb, 0xbef9a3f7, 0xc67178f2,
+}
+local function be32(n)
+  return string.char(band(rshift(n, 24), 255), band(rshift(n, 16), 255), band(rshift(n, 8), 255), band(n, 255))
+end
+local function sha256(msg)
+  local H = {0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19}
+  local bits = #msg * 8
+  msg = msg .. "\128" .. string.rep("\0", (55 - #msg) % 64) .. be32(math.floor(bits / 4294967296)) .. be32(bits % 4294967296)
+  local w = {}
+  for i = 1, #msg, 64 do
+    for j = 0, 15 do
+      local b1, b2, b3, b4 = msg:byte(i + j * 4, i + j * 4 + 3)
+      w[j] = bor(lshift(b1, 24), lshift(b2, 16), lshift(b3, 8), b4)
+    end
+    for j = 16, 63 do
+      local s0 = bxor(ror(w[j - 15], 7), ror(w[j - 15], 18), rshift(w[j - 15], 3))
+      local s1 = bxor(ror(w[j - 2], 17), ror(w[j - 2], 19), rshift(w[j - 2], 10))
+      w[j] = tobit(w[j - 16] + s0 + w[j - 7] + s1)
+    end
+    local a, b, c, d, e, f, g, h = H[1], H[2], H[3], H[4], H[5], H[6], H[7], H[8]
+    for j = 0, 63 do
+      local t1 = h + bxor(ror(e, 6), ror(e, 11), ror(e, 25)) + bxor(band(e, f), band(bnot(e), g)) + K[j + 1] + w[j]
+      local t2 = bxor(ror(a, 2), ror(a, 13), ror(a, 22)) + bxor(band(a, b), band(a, c), band(b, c))
+      h, g, f, e, d, c, b, a = g, f, e, tobit(d + t1), c, b, a, tobit(t1 + t2)
+    end
+    H[1], H[2], H[3], H[4] = tobit(H[1] + a), tobit(H[2] + b), tobit(H[3] + c), tobit(H[4] + d)
+    H[5], H[6], H[7], H[8] = tobit(H[5] + e), tobit(H[6] + f), tobit(H[7] + g), tobit(H[8] + h)
+  end
+  local out = {}
+  for i = 1, 8 do out[i] = be32(H[i]) end
+  return table.concat(out)
+end
+local function hmac_sha256(key, msg)
+  if #key > 64 then key = sha256(key) end
+  key = key .. string.rep("\0", 64 - #key)
+  local ipad = key:gsub(".", function(ch) return string.char(bxor(ch:byte(), 0x36)) end)
+  local opad = key:gsub(".", function(ch) return string.char(bxor(ch:byte(), 0x5c)) end)
+  return sha256(opad .. sha256(ipad .. msg))
+end
+local function hex(s)
+  return (s:gsub(".", function(ch) return string.format("%02x", ch:byte()) end))
+end
+`
+
+// redactLua holds the Lua functions used by the redact processor. It expects the detectors, fields, action and
+// hash_key variables to be defined first, and redactHashLua when action is "hash".
+const redactLua = `
+local function luhn(m)
+  local d = m:gsub("%D", "")
+  if #d < 13 or #d > 19 then return false end
+  local sum = 0
+  local alt = false
+  for i = #d, 1, -1 do
+    local n = tonumber(d:sub(i, i))
+    if alt then n = n * 2 if n > 9 then n = n - 9 end end
+    sum = sum + n
+    alt = not alt
+  end
+  return sum % 10 == 0
+end
+local function ipv4(m)
+  for o in m:gmatch("%d+") do
+    if #o > 3 or tonumber(o) > 255 then return false end
+  end
+  return true
+end
+local function ipv6(m)
+  local _, colons = m:gsub(":", "")
+  if colons < 2 or colons > 7 then return false end
+  if colons < 7 and not m:find("::", 1, true) then return false end
+  local groups = 0
+  for g in m:gmatch("[^:]+") do
+    if #g > 4 then return false end
+    groups = groups + 1
+  end
+  return groups >= 2
+end
+local checks = {ipv4 = ipv4, ipv6 = ipv6}
+local function replacement(m)
+  if action == "hash" then return "[REDACTED:" .. hex(hmac_sha256(hash_key, m)):sub(1, 16) .. "]" end
+  return "[REDACTED]"
+end
+local function isdigit(s, i)
+  local c = s:byte(i)
+  return c ~= nil and c >= 48 and c <= 57
+end
+local function cards(m)
+  local out, n, i = {}, 0, 1
+  while i <= #m do
+    local last
+    if isdigit(m, i) and not isdigit(m, i - 1) then
+      local ends, digits, k = {}, 0, i
+      while k <= #m and digits < 19 do
+        if isdigit(m, k) then
+          digits = digits + 1
+          if digits >= 13 and not isdigit(m, k + 1) then ends[#ends + 1] = k end
+        end
+        k = k + 1
+      end
+      for e = #ends, 1, -1 do
+        if luhn(m:sub(i, ends[e])) then last = ends[e] break end
+      end
+    end
+    if last then
+      out[#out + 1] = replacement(m:sub(i, last))
+      n = n + 1
+      i = last + 1
+    else
+      out[#out + 1] = m:sub(i, i)
+      i = i + 1
+    end
+  end
+  return table.concat(out), n
+end
+local scanners = {cards = cards}
+local function redact_string(s)
+  local total = 0
+  for _, d in ipairs(detectors) do
+    s = s:gsub(d[1], function(m)
+      local scan = scanners[d[2]]
+      if scan then
+        local r, n = scan(m)
+        total = total + n
+        return r
+      end
+      if d[2] == "" or checks[d[2]](m) then
+        total = total + 1
+        return replacement(m)
+      end
+    end)
+  end
+  return s, total
+end
+local changed = false
+local function visit(t, k)
+  local v = t[k]
+  if type(v) == "string" then
+    local s, n = redact_string(v)
+    if n > 0 then
+      changed = true
+      if action == "drop_field" then t[k] = nil else t[k] = s end
+    end
+  elseif type(v) == "table" then
+    for kk in pairs(v) do visit(v, kk) end
+  end
+end
+function redact(tag, timestamp, record)
+  changed = false
+  if fields then
+    for _, f in ipairs(fields) do visit(record, f) end
+  else
+    for k in pairs(record) do visit(record, k) end
+  end
+  if changed then return 2, timestamp, record end
+  return 0, timestamp, record
+end
+`
+
+// A LoggingProcessorRedact replaces sensitive data in log records.
+type LoggingProcessorRedact struct {
+	ConfigComponent `yaml:",inline"`
+	// Fields are the top-level fields to redact. By default, all the strings in the record are redacted.
+	Fields []string `yaml:"fields,omitempty"`
+	// Detectors are the built-in detectors to use. By default, all of them are used.
+	Detectors []string `yaml:"detectors,omitempty" validate:"dive,oneof=email credit_card ipv4 ipv6 token"`
+	// CustomPatterns are additional Lua patterns to redact, such as "ssn=%d%d%d%-%d%d%-%d%d%d%d".
+	// Fluent Bit has no filter that replaces regex matches, so the processor is implemented in Lua, and the patterns
+	// are matched with Lua's string.gsub. Lua patterns are not regexes: they have no alternation, and their
+	// quantifiers only apply to a single character or class.
+	CustomPatterns []string `yaml:"custom_patterns,omitempty" validate:"dive,required,lua_pattern"`
+	// Action is "mask" (the default), "hash" or "drop_field".
+	// "hash" replaces matches with the first 64 bits of their HMAC-SHA256 keyed with hash_key, so that equal values
+	// can still be correlated by those who don't know the key.
+	Action  string `yaml:"action,omitempty" validate:"omitempty,oneof=mask hash drop_field"`
+	HashKey string `yaml:"hash_key,omitempty" validate:"required_if_oneof=Action hash,omitempty,min=16"`
+}
+
+// luaPatternError returns the error that Lua would raise when matching with the pattern p, if any.
+func luaPatternError(p string) error {
+	var open []bool // whether each capture is closed
+	level := 0      // number of unclosed captures
+	for i := 0; i < len(p); i++ {
+		switch p[i] {
+		case '(':
+			open = append(open, true)
+			level++
+		case ')':
+			closed := false
+			for c := len(open) - 1; c >= 0; c-- {
+				if open[c] {
+					open[c] = false
+					level--
+					closed = true
+					break
+				}
+			}
+			if !closed {
+				return fmt.Errorf("invalid pattern capture")
+			}
+		case '%':
+			i++
+			if i == len(p) {
+				return fmt.Errorf("malformed pattern (ends with '%%')")
+			}
+			switch c := p[i]; {
+			case c == 'b':
+				if i+2 >= len(p) {
+					return fmt.Errorf("missing arguments to '%%b'")
+				}
+				i += 2
+			case c == 'f':
+				if i+1 == len(p) || p[i+1] != '[' {
+					return fmt.Errorf("missing '[' after '%%f' in pattern")
+				}
+			case c >= '1' && c <= '9':
+				if n := int(c - '1'); n >= len(open) || open[n] {
+					return fmt.Errorf("invalid capture index")
+				}
+			case c == '0':
+				return fmt.Errorf("invalid capture index")
+			}
+		case '[':
+			i++
+			if i < len(p) && p[i] == '^' {
+				i++
+			}
+			// The first character of a set is never its end, so "[]]" matches "]".
+			for first := true; ; first = false {
+				if i >= len(p) {
+					return fmt.Errorf("malformed pattern (missing ']')")
+				}
+				if p[i] == ']' && !first {
+					break
+				}
+				if p[i] == '%' {
+					i++
+				}
+				i++
+			}
+		}
+	}
+	if level > 0 {
+		return fmt.Errorf("unfinished capture")
+	}
+	return nil
+}
+
+func (p LoggingProcessorRedact) Type() string {
+	return "redact"
+}
+
+// script returns the Lua code of the processor.
+func (p LoggingProcessorRedact) script() string {
+	enabled := map[string]bool{}
+	for _, d := range p.Detectors {
+		enabled[d] = true
+	}
+	var detectors []string
+	for _, d := range redactDetectors {
+		if len(p.Detectors) == 0 || enabled[d.Name] {
+			detectors = append(detectors, fmt.Sprintf("{%s, %s}", fluentbit.LuaQuote(d.Pattern), fluentbit.LuaQuote(d.Check)))
+		}
+	}
+	for _, pattern := range p.CustomPatterns {
+		detectors = append(detectors, fmt.Sprintf(`{%s, ""}`, fluentbit.LuaQuote(pattern)))
+	}
+	fields := "nil"
+	if len(p.Fields) > 0 {
+		var quoted []string
+		for _, f := range p.Fields {
+			quoted = append(quoted, fluentbit.LuaQuote(f))
+		}
+		fields = fmt.Sprintf("{%s}", strings.Join(quoted, ", "))
+	}
+	action := p.Action
+	if action == "" {
+		action = "mask"
+	}
+	lines := []string{
+		fmt.Sprintf("local detectors = {%s}", strings.Join(detectors, ", ")),
+		fmt.Sprintf("local fields = %s", fields),
+		fmt.Sprintf("local action = %s", fluentbit.LuaQuote(action)),
+	}
+	if action == "hash" {
+		lines = append(lines, fmt.Sprintf("local hash_key = %s", fluentbit.LuaQuote(p.HashKey)), redactHashLua)
+	}
+	return strings.Join(append(lines, redactLua), "\n")
+}
+
+// scriptFile returns the name and the content of the file to write the script to.
+// The script is too long to be inlined in the Fluent Bit config, whose lines are limited to 4096 bytes.
+func (p LoggingProcessorRedact) scriptFile() (string, string, error) {
+	script := p.script()
+	return luaScriptName(script), script, nil
+}
+
+func (p LoggingProcessorRedact) Components(tag, uid string) []fluentbit.Component {
+	return []fluentbit.Component{luaScriptFilterComponent(tag, luaScriptName(p.script()), "redact")}
+}
+
+func init() {
+	LoggingProcessorTypes.RegisterType(func() Component { return &LoggingProcessorRedact{} })
+}
+
+// A LoggingProcessorSample keeps a random sample of the records.
+type LoggingProcessorSample struct {
+	ConfigComponent `yaml:",inline"`
+	// Percentage is the percentage of records to keep.
+	Percentage *float64 `yaml:"percentage" validate:"required,min=0,max=100"`
+}
+
+func (p LoggingProcessorSample) Type() string {
+	return "sample"
+}
+
+func (p LoggingProcessorSample) Components(tag, uid string) []fluentbit.Component {
+	// Each filter runs in its own Lua state, and all of them start at the same time. The seed is salted with the
+	// tag and uid so that the processors don't draw the same sequence and keep the same records.
+	salt := fnv.New32a()
+	salt.Write([]byte(tag + "." + uid))
+	code := fluentbit.LuaCode(
+		fmt.Sprintf("local percentage = %s", strconv.FormatFloat(*p.Percentage, 'f', -1, 64)),
+		fmt.Sprintf("local salt = %d", salt.Sum32()),
+		`math.randomseed(os.time() + salt)
+		function sample(tag, timestamp, record)
+		  if math.random() * 100 < percentage then return 0, timestamp, record end
+		  return -1, timestamp, record
+		end`,
+	)
+	c := fluentbit.LuaFilterComponent(tag, "sample", code)
+	c.Config["Alias"] = droppingFilterAlias(p.Type(), tag, uid)
+	return []fluentbit.Component{c}
+}
+
+// A LoggingProcessorRateLimit drops the records above a given rate, either for all the records of the receiver or
+// separately for each value of a field.
+type LoggingProcessorRateLimit struct {
+	ConfigComponent `yaml:",inline"`
+	// Rate is the number of records per second to keep.
+	Rate int `yaml:"rate" validate:"required,min=1"`
+	// Burst is the number of records that can be kept at once. It defaults to Rate.
+	Burst int `yaml:"burst,omitempty" validate:"omitempty,min=1"`
+	// Field, if set, is the field whose values are rate limited separately.
+	Field string `yaml:"field,omitempty"`
+}
+
+func (p LoggingProcessorRateLimit) Type() string {
+	return "rate_limit"
+}
+
+func (p LoggingProcessorRateLimit) Components(tag, uid string) []fluentbit.Component {
+	burst := p.Burst
+	if burst == 0 {
+		burst = p.Rate
+	}
+	var c fluentbit.Component
+	if p.Field == "" {
+		// https://docs.fluentbit.io/manual/pipeline/filters/throttle
+		// throttle limits the average rate over a window of intervals, so the window has to fit the burst.
+		window := (burst + p.Rate - 1) / p.Rate
+		c = fluentbit.Component{
+			Kind: "FILTER",
+			Config: map[string]string{
+				"Name":     "throttle",
+				"Match":    tag,
+				"Rate":     strconv.Itoa(p.Rate),
+				"Window":   strconv.Itoa(window),
+				"Interval": "1s",
+			},
+		}
+	} else {
+		// Token buckets, one per value of the field. The buckets are reset if there are too many values.
+		code := fluentbit.LuaCode(
+			fmt.Sprintf("local field = %s", fluentbit.LuaQuote(p.Field)),
+			fmt.Sprintf("local rate = %d", p.Rate),
+			fmt.Sprintf("local burst = %d", burst),
+			`local buckets = {}
+			local count = 0
+			function rate_limit(tag, timestamp, record)
+			  local key = tostring(record[field])
+			  local now = os.time()
+			  local b = buckets[key]
+			  if b == nil then
+			    if count >= 10000 then buckets = {} count = 0 end
+			    b = {tokens = burst, time = now}
+			    buckets[key] = b
+			    count = count + 1
+			  end
+			  b.tokens = math.min(burst, b.tokens + (now - b.time) * rate)
+			  b.time = now
+			  if b.tokens < 1 then return -1, timestamp, record end
+			  b.tokens = b.tokens - 1
+			  return 0, timestamp, record
+			end`,
+		)
+		c = fluentbit.LuaFilterComponent(tag, "rate_limit", code)
+	}
+	c.Config["Alias"] = droppingFilterAlias(p.Type(), tag, uid)
+	return []fluentbit.Component{c}
+}
+
+// droppingProcessorTypes are the types of the processors that drop records to limit the volume of logs.
+var droppingProcessorTypes = []string{"sample", "rate_limit"}
+
+// droppingFilterAlias names the filters of the processors that drop records, so that their drop counts can be
+// told apart in the agent metrics.
+func droppingFilterAlias(processorType, tag, uid string) string {
+	return fmt.Sprintf("%s.%s.%s", processorType, tag, uid)
+}
+
+// droppingFilterAliasRegex returns a regex that matches the aliases returned by droppingFilterAlias.
+func droppingFilterAliasRegex() string {
+	var types []string
+	for _, t := range droppingProcessorTypes {
+		types = append(types, regexp.QuoteMeta(t))
+	}
+	return fmt.Sprintf(`(?:%s)\..+`, strings.Join(types, "|"))
+}
+
+// dropsRecords reports whether any pipeline uses a processor that drops records to limit the volume of logs.
+func (l *Logging) dropsRecords() bool {
+	if l == nil || l.Service == nil {
+		return false
+	}
+	for _, p := range l.Service.Pipelines {
+		for _, id := range p.ProcessorIDs {
+			if processor, ok := l.Processors[id]; ok {
+				for _, t := range droppingProcessorTypes {
+					if processor.Type() == t {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+func init() {
+	LoggingProcessorTypes.RegisterType(func() Component { return &LoggingProcessorSample{} })
+	LoggingProcessorTypes.RegisterType(func() Component { return &LoggingProcessorRateLimit{} })
+}
+
+// A LoggingProcessorLua runs a user-provided Lua function on each record.
+// The script is written to a file next to the main Fluent Bit config, see luaScripts.
+type LoggingProcessorLua struct {
+	ConfigComponent `yaml:",inline"`
+	// Script is the inline Lua code.
+	Script string `yaml:"script,omitempty" validate:"required_without=ScriptPath,excluded_with=ScriptPath"`
+	// ScriptPath is the path of a file holding the Lua code. It is read when the Fluent Bit config is generated.
+	ScriptPath string `yaml:"script_path,omitempty"`
+	// Call is the name of the global function to call, with the signature described at
+	// https://docs.fluentbit.io/manual/pipeline/filters/lua#callback-prototype.
+	Call string `yaml:"call" validate:"required,fieldname"`
+}
+
+func (p LoggingProcessorLua) Type() string {
+	return "lua"
+}
+
+// scriptName returns the name of the file to write the script to.
+// It is derived from the config rather than from the content of the script, which may not be readable, so that
+// Components can't fail.
+func (p LoggingProcessorLua) scriptName() string {
+	if p.ScriptPath != "" {
+		return luaScriptName("script_path:" + p.ScriptPath)
+	}
+	return luaScriptName(p.Script)
+}
+
+// scriptFile returns the name and the content of the file to write the script to.
+// It returns an error if the script cannot be read or does not mention the function to call. Other mistakes, such as
+// syntax errors, are reported by Fluent Bit when it loads the script.
+func (p LoggingProcessorLua) scriptFile() (string, string, error) {
+	script := p.Script
+	if p.ScriptPath != "" {
+		data, err := ioutil.ReadFile(p.ScriptPath)
+		if err != nil {
+			return "", "", fmt.Errorf("can't read Lua script: %w", err)
+		}
+		script = string(data)
+	}
+	// call is validated as a fieldname, so it doesn't need to be quoted.
+	if !regexp.MustCompile(`\b` + p.Call + `\b`).MatchString(script) {
+		return "", "", fmt.Errorf("script does not contain the function %q", p.Call)
+	}
+	return p.scriptName(), script, nil
+}
+
+func (p LoggingProcessorLua) Components(tag, uid string) []fluentbit.Component {
+	return []fluentbit.Component{luaScriptFilterComponent(tag, p.scriptName(), p.Call)}
+}
+
+// luaScriptName returns the name of the file holding script, which is derived from its content.
+func luaScriptName(script string) string {
+	sum := sha256.Sum256([]byte(script))
+	return fmt.Sprintf("lua_%x.lua", sum[:8])
+}
+
+// luaScriptFilterComponent returns a filter that runs the Lua function call, defined by the script written to the file
+// name by luaScripts, on each record.
+func luaScriptFilterComponent(tag, name, call string) fluentbit.Component {
+	return fluentbit.Component{
+		Kind: "FILTER",
+		Config: map[string]string{
+			"Name":  "lua",
+			"Match": tag,
+			// Relative paths are resolved from the directory of the main config.
+			"script": name,
+			"call":   call,
+		},
+	}
+}
+
+// A luaScripter is a receiver or a processor whose Lua code is written to a file.
+type luaScripter interface {
+	// scriptFile returns the name and the content of the file to write the script to.
+	scriptFile() (string, string, error)
+}
+
+// luaScripts returns the content of the Lua scripts used by the pipelines, by file name.
+func (l *Logging) luaScripts() (map[string]string, error) {
+	scripts := map[string]string{}
+	if l == nil || l.Service == nil {
+		return scripts, nil
+	}
+	for _, p := range l.Service.Pipelines {
+		for _, id := range p.ReceiverIDs {
+			if receiver, ok := l.Receivers[id].(luaScripter); ok {
+				name, script, _ := receiver.scriptFile()
+				scripts[name] = script
+			}
+		}
+		for _, id := range p.ProcessorIDs {
+			processor, ok := l.Processors[id].(luaScripter)
+			if !ok {
+				continue
+			}
+			name, script, err := processor.scriptFile()
+			if err != nil {
+				return nil, fmt.Errorf("processor %q: %w", id, err)
+			}
+			scripts[name] = script
+		}
+	}
+	return scripts, nil
+}
+
+func init() {
+	LoggingProcessorTypes.RegisterType(func() Component { return &LoggingProcessorLua{} })
+}
+
 var LegacyBuiltinProcessors = map[string]LoggingProcessor{
 	"lib:default_message_parser": &LoggingProcessorParseRegex{
 		Regex: `^(?<message>.*)$`,
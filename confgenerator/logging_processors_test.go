@@ -0,0 +1,222 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confgenerator
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// newLuaState returns a Lua state with the subset of the LuaJIT bit library that the generated scripts use, since
+// Fluent Bit runs them with LuaJIT.
+func newLuaState(t *testing.T) *lua.LState {
+	t.Helper()
+	L := lua.NewState()
+	t.Cleanup(L.Close)
+	tobit := func(L *lua.LState, n int) int32 {
+		return int32(uint32(int64(L.CheckNumber(n))))
+	}
+	fold := func(op func(a, b int32) int32) lua.LGFunction {
+		return func(L *lua.LState) int {
+			r := tobit(L, 1)
+			for i := 2; i <= L.GetTop(); i++ {
+				r = op(r, tobit(L, i))
+			}
+			L.Push(lua.LNumber(r))
+			return 1
+		}
+	}
+	shift := func(op func(a uint32, n uint) uint32) lua.LGFunction {
+		return func(L *lua.LState) int {
+			L.Push(lua.LNumber(int32(op(uint32(tobit(L, 1)), uint(tobit(L, 2))&31))))
+			return 1
+		}
+	}
+	L.SetGlobal("bit", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"tobit": fold(func(a, b int32) int32 { return a }),
+		"bnot": func(L *lua.LState) int {
+			L.Push(lua.LNumber(^tobit(L, 1)))
+			return 1
+		},
+		"band":   fold(func(a, b int32) int32 { return a & b }),
+		"bor":    fold(func(a, b int32) int32 { return a | b }),
+		"bxor":   fold(func(a, b int32) int32 { return a ^ b }),
+		"lshift": shift(func(a uint32, n uint) uint32 { return a << n }),
+		"rshift": shift(func(a uint32, n uint) uint32 { return a >> n }),
+		"ror":    shift(func(a uint32, n uint) uint32 { return a>>n | a<<(32-n) }),
+	}))
+	return L
+}
+
+// luaFunctions runs script, followed by a statement returning the named local functions, and returns them.
+func luaFunctions(t *testing.T, L *lua.LState, script string, names ...string) []*lua.LFunction {
+	t.Helper()
+	if err := L.DoString(script + "\nreturn " + strings.Join(names, ", ")); err != nil {
+		t.Fatalf("failed to run script: %v", err)
+	}
+	var functions []*lua.LFunction
+	for i := range names {
+		functions = append(functions, L.Get(i-len(names)).(*lua.LFunction))
+	}
+	L.Pop(len(names))
+	return functions
+}
+
+// callLua calls f with args and returns its first result as a string.
+func callLua(t *testing.T, L *lua.LState, f *lua.LFunction, args ...lua.LValue) string {
+	t.Helper()
+	if err := L.CallByParam(lua.P{Fn: f, NRet: 1, Protect: true}, args...); err != nil {
+		t.Fatalf("Lua call failed: %v", err)
+	}
+	defer L.Pop(1)
+	return L.Get(-1).String()
+}
+
+func TestRedactSHA256(t *testing.T) {
+	L := newLuaState(t)
+	f := luaFunctions(t, L, redactHashLua, "hex", "sha256")
+	hexLua, sha256Lua := f[0], f[1]
+	digest := func(msg string) string {
+		return callLua(t, L, hexLua, lua.LString(callLua(t, L, sha256Lua, lua.LString(msg))))
+	}
+	// Test vectors from FIPS 180-2, appendix B.
+	for msg, want := range map[string]string{
+		"":    "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		"abc": "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		"abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq": "248d6a61d20638b8e5c026930c3e6039a33ce45964ff2167f6ecedd419db06c1",
+	} {
+		if got := digest(msg); got != want {
+			t.Errorf("sha256(%q) = %s, want %s", msg, got, want)
+		}
+	}
+	// Messages around the block size exercise the padding.
+	for n := 50; n <= 130; n++ {
+		msg := strings.Repeat("\xa5", n)
+		want := sha256.Sum256([]byte(msg))
+		if got := digest(msg); got != hex.EncodeToString(want[:]) {
+			t.Errorf("sha256 of %d bytes = %s, want %x", n, got, want)
+		}
+	}
+}
+
+func TestRedactHMACSHA256(t *testing.T) {
+	L := newLuaState(t)
+	f := luaFunctions(t, L, redactHashLua, "hex", "hmac_sha256")
+	hexLua, hmacLua := f[0], f[1]
+	// Test cases 1, 2, 3 and 6 from RFC 4231.
+	for _, tc := range []struct{ key, data, want string }{
+		{strings.Repeat("\x0b", 20), "Hi There", "b0344c61d8db38535ca8afceaf0bf12b881dc200c9833da726e9376c2e32cff7"},
+		{"Jefe", "what do ya want for nothing?", "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+		{strings.Repeat("\xaa", 20), strings.Repeat("\xdd", 50), "773ea91e36800e46854db8ebd09181a72959098b3ef8c122d9635514ced565fe"},
+		{strings.Repeat("\xaa", 131), "Test Using Larger Than Block-Size Key - Hash Key First", "60e431591ee0b67f0d8a26aacbf5b77f8e0bc6213728c5140546040f0ee37f54"},
+	} {
+		got := callLua(t, L, hexLua, lua.LString(callLua(t, L, hmacLua, lua.LString(tc.key), lua.LString(tc.data))))
+		if got != tc.want {
+			t.Errorf("hmac_sha256(%q, %q) = %s, want %s", tc.key, tc.data, got, tc.want)
+		}
+		mac := hmac.New(sha256.New, []byte(tc.key))
+		mac.Write([]byte(tc.data))
+		if want := hex.EncodeToString(mac.Sum(nil)); got != want {
+			t.Errorf("hmac_sha256(%q, %q) = %s, crypto/hmac got %s", tc.key, tc.data, got, want)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		processor LoggingProcessorRedact
+		message   string
+		want      string
+	}{
+		{
+			name:    "mask",
+			message: "user jane.doe@example.com paid with 4111 1111 1111 1111 from 10.0.0.1, order 1234567890123",
+			want:    "user [REDACTED] paid with [REDACTED] from [REDACTED], order 1234567890123",
+		},
+		{
+			name:      "hash",
+			processor: LoggingProcessorRedact{Detectors: []string{"email"}, Action: "hash", HashKey: "0123456789abcdef"},
+			message:   "from jane.doe@example.com",
+			want:      "from [REDACTED:" + hmacPrefix("0123456789abcdef", "jane.doe@example.com") + "]",
+		},
+		{
+			name:      "custom pattern",
+			processor: LoggingProcessorRedact{Detectors: []string{"email"}, CustomPatterns: []string{"ssn=%d%d%d%-%d%d%-%d%d%d%d"}},
+			message:   "ssn=123-45-6789 ssn=12-345-6789",
+			want:      "[REDACTED] ssn=12-345-6789",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			L := newLuaState(t)
+			if err := L.DoString(tc.processor.script()); err != nil {
+				t.Fatalf("failed to run script: %v", err)
+			}
+			record := L.NewTable()
+			record.RawSetString("message", lua.LString(tc.message))
+			if err := L.CallByParam(lua.P{Fn: L.GetGlobal("redact"), NRet: 3, Protect: true}, lua.LString("tag"), lua.LNumber(0), record); err != nil {
+				t.Fatalf("redact() failed: %v", err)
+			}
+			if got := record.RawGetString("message").String(); got != tc.want {
+				t.Errorf("redact() set message to %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// hmacPrefix returns the first 16 hex digits of the HMAC-SHA256 of data keyed with key.
+func hmacPrefix(key, data string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+func TestLuaPatternError(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		want    string
+	}{
+		{"ssn=%d%d%d%-%d%d%-%d%d%d%d", ""},
+		{"[]%]]+", ""},
+		{"[^%s]-%.", ""},
+		{"%b()", ""},
+		{"%f[%w]%w+", ""},
+		{"(['\"])[^%1]-%1", ""},
+		{"(a)(b)%2", ""},
+		{"a%", "malformed pattern (ends with '%')"},
+		{"[a-z", "malformed pattern (missing ']')"},
+		{"[a%]", "malformed pattern (missing ']')"},
+		{"%b(", "missing arguments to '%b'"},
+		{"%fa", "missing '[' after '%f' in pattern"},
+		{"a)", "invalid pattern capture"},
+		{"(a", "unfinished capture"},
+		{"(a%1)", "invalid capture index"},
+		{"(a)%2", "invalid capture index"},
+	} {
+		err := luaPatternError(tc.pattern)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("luaPatternError(%q) = %q, want %q", tc.pattern, got, tc.want)
+		}
+	}
+}
@@ -17,7 +17,10 @@ package confgenerator
 import (
 	"fmt"
 	"path"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/GoogleCloudPlatform/ops-agent/confgenerator/fluentbit"
 )
@@ -29,12 +32,28 @@ func DBPath(tag string) string {
 	return path.Join("${buffers_dir}", dir)
 }
 
+// LoggingReceiverShared holds the configuration fields that all logging receivers have.
+type LoggingReceiverShared struct {
+	// LogName is the name of the log the entries are written to. It defaults to the receiver ID.
+	// "${field}" is replaced by the value of field in each record, after the processors have run.
+	LogName string `yaml:"log_name,omitempty" validate:"omitempty,log_name"`
+}
+
+func (s LoggingReceiverShared) logName() string {
+	return s.LogName
+}
+
 // A LoggingReceiverFiles represents the user configuration for a file receiver (fluentbit's tail plugin).
 type LoggingReceiverFiles struct {
 	ConfigComponent `yaml:",inline"`
 	// TODO: Use LoggingReceiverFilesMixin after figuring out the validation story.
 	IncludePaths []string `yaml:"include_paths,omitempty" validate:"required"`
 	ExcludePaths []string `yaml:"exclude_paths,omitempty"`
+	// RecordLogFilePath attaches the path of the file each entry was read from as the "agent.googleapis.com/log_file_path" label.
+	// The label is added after the processors of the pipeline, whose parsers then keep the fields they don't parse.
+	RecordLogFilePath               bool `yaml:"record_log_file_path,omitempty"`
+	LoggingReceiverShared           `yaml:",inline"`
+	LoggingReceiverFilesTailOptions `yaml:",inline"`
 }
 
 func (r LoggingReceiverFiles) Type() string {
@@ -42,15 +61,101 @@ func (r LoggingReceiverFiles) Type() string {
 }
 
 func (r LoggingReceiverFiles) Components(tag string) []fluentbit.Component {
-	return LoggingReceiverFilesMixin{
-		IncludePaths: r.IncludePaths,
-		ExcludePaths: r.ExcludePaths,
+	c := LoggingReceiverFilesMixin{
+		IncludePaths:                    r.IncludePaths,
+		ExcludePaths:                    r.ExcludePaths,
+		LoggingReceiverFilesTailOptions: r.LoggingReceiverFilesTailOptions,
 	}.Components(tag)
+	if r.RecordLogFilePath {
+		c[0].Config["Path_Key"] = logFilePathKey
+	}
+	return c
+}
+
+// logFilePathKey is the field holding the path of the file each entry was read from, until it is moved to labels.
+const logFilePathKey = "agent.googleapis.com/log_file_path"
+
+// postProcessorComponents moves the path of the file to labels once the processors have run.
+func (r LoggingReceiverFiles) postProcessorComponents(tag string) []fluentbit.Component {
+	if !r.RecordLogFilePath {
+		return nil
+	}
+	return append([]fluentbit.Component{{
+		Kind: "FILTER",
+		Config: map[string]string{
+			"Name":   "modify",
+			"Match":  tag,
+			"Rename": fmt.Sprintf("%s %s", logFilePathKey, labelPrefix+logFilePathKey),
+		},
+	}}, nestLabelsComponents(tag)...)
+}
+
+// LoggingReceiverFilesTailOptions holds the settings of fluentbit's tail plugin that can be tuned by users.
+// Unset fields keep the defaults of LoggingReceiverFilesMixin.
+type LoggingReceiverFilesTailOptions struct {
+	ReadFromHead    *bool  `yaml:"read_from_head,omitempty"`
+	BufferChunkSize string `yaml:"buffer_chunk_size,omitempty" validate:"omitempty,size"`
+	BufferMaxSize   string `yaml:"buffer_max_size,omitempty" validate:"omitempty,size"`
+	MemBufLimit     string `yaml:"mem_buf_limit,omitempty" validate:"omitempty,size"`
+	RotateWait      string `yaml:"rotate_wait,omitempty" validate:"omitempty,duration=1s,whole_seconds"`
+	SkipLongLines   *bool  `yaml:"skip_long_lines,omitempty"`
+	RefreshInterval string `yaml:"refresh_interval,omitempty" validate:"omitempty,duration=1s,whole_seconds"`
+	// IgnoreOlder skips the files that were last modified longer ago.
+	IgnoreOlder string `yaml:"ignore_older,omitempty" validate:"omitempty,duration=1s,whole_seconds"`
+	// Encoding converts files from UTF-16 to UTF-8.
+	Encoding string `yaml:"encoding,omitempty" validate:"omitempty,oneof=UTF-16LE UTF-16BE"`
+}
+
+// seconds formats a duration validated by whole_seconds as a number of seconds.
+func seconds(d string) string {
+	t, _ := time.ParseDuration(d)
+	return strconv.Itoa(int(t.Seconds()))
+}
+
+// onOff formats a bool as a fluentbit switch.
+func onOff(b bool) string {
+	if b {
+		return "On"
+	}
+	return "Off"
+}
+
+// apply overrides the defaults in config with the options that are set.
+func (o LoggingReceiverFilesTailOptions) apply(config map[string]string) {
+	if o.ReadFromHead != nil {
+		config["Read_from_Head"] = onOff(*o.ReadFromHead)
+	}
+	if o.BufferChunkSize != "" {
+		config["Buffer_Chunk_Size"] = o.BufferChunkSize
+	}
+	if o.BufferMaxSize != "" {
+		config["Buffer_Max_Size"] = o.BufferMaxSize
+	}
+	if o.MemBufLimit != "" {
+		config["Mem_Buf_Limit"] = o.MemBufLimit
+	}
+	if o.RotateWait != "" {
+		config["Rotate_Wait"] = seconds(o.RotateWait)
+	}
+	if o.SkipLongLines != nil {
+		config["Skip_Long_Lines"] = onOff(*o.SkipLongLines)
+	}
+	if o.RefreshInterval != "" {
+		config["Refresh_Interval"] = seconds(o.RefreshInterval)
+	}
+	if o.IgnoreOlder != "" {
+		config["Ignore_Older"] = seconds(o.IgnoreOlder)
+	}
+	if o.Encoding != "" {
+		config["Unicode.Encoding"] = o.Encoding
+	}
 }
 
 type LoggingReceiverFilesMixin struct {
-	IncludePaths []string `yaml:"include_paths,omitempty"`
-	ExcludePaths []string `yaml:"exclude_paths,omitempty"`
+	IncludePaths                    []string `yaml:"include_paths,omitempty"`
+	ExcludePaths                    []string `yaml:"exclude_paths,omitempty"`
+	LoggingReceiverShared           `yaml:",inline"`
+	LoggingReceiverFilesTailOptions `yaml:",inline"`
 }
 
 func (r LoggingReceiverFilesMixin) Components(tag string) []fluentbit.Component {
@@ -88,6 +193,7 @@ func (r LoggingReceiverFilesMixin) Components(tag string) []fluentbit.Component
 		// as a hint to set "how much data can be up in memory", once the limit is reached it continues writing to disk.
 		"Mem_Buf_Limit": "10M",
 	}
+	r.LoggingReceiverFilesTailOptions.apply(config)
 	if len(r.ExcludePaths) > 0 {
 		// TODO: Escaping?
 		config["Exclude_Path"] = strings.Join(r.ExcludePaths, ",")
@@ -104,7 +210,8 @@ func init() {
 
 // A LoggingReceiverSyslog represents the configuration for a syslog protocol receiver.
 type LoggingReceiverSyslog struct {
-	ConfigComponent `yaml:",inline"`
+	ConfigComponent       `yaml:",inline"`
+	LoggingReceiverShared `yaml:",inline"`
 
 	TransportProtocol string `yaml:"transport_protocol,omitempty" validate:"oneof=tcp udp"`
 	ListenHost        string `yaml:"listen_host,omitempty" validate:"required,ip"`
@@ -154,7 +261,8 @@ func init() {
 
 // A LoggingReceiverTCP represents the configuration for a TCP receiver.
 type LoggingReceiverTCP struct {
-	ConfigComponent `yaml:",inline"`
+	ConfigComponent       `yaml:",inline"`
+	LoggingReceiverShared `yaml:",inline"`
 
 	Format     string `yaml:"format,omitempty" validate:"required,oneof=json"`
 	ListenHost string `yaml:"listen_host,omitempty" validate:"omitempty,ip"`
@@ -202,7 +310,8 @@ func init() {
 
 // A LoggingReceiverWindowsEventLog represents the user configuration for a Windows event log receiver.
 type LoggingReceiverWindowsEventLog struct {
-	ConfigComponent `yaml:",inline"`
+	ConfigComponent       `yaml:",inline"`
+	LoggingReceiverShared `yaml:",inline"`
 
 	Channels []string `yaml:"channels,omitempty,flow" validate:"required"`
 }
@@ -239,17 +348,44 @@ func init() {
 	LoggingReceiverTypes.RegisterType(func() Component { return &LoggingReceiverWindowsEventLog{} }, "windows")
 }
 
+// journaldPriorities are the names of the syslog priorities, in the order of their numeric values.
+var journaldPriorities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
 // A LoggingReceiverSystemd represents the user configuration for a Systemd/journald receiver.
 type LoggingReceiverSystemd struct {
-	ConfigComponent `yaml:",inline"`
+	ConfigComponent       `yaml:",inline"`
+	LoggingReceiverShared `yaml:",inline"`
+	// Units are the systemd units to read the entries of, e.g. "sshd.service". All units are read by default.
+	Units []string `yaml:"units,omitempty" validate:"dive,required"`
+	// Priority is the lowest priority of the entries to read, e.g. "warning" also reads "err" entries.
+	Priority string `yaml:"priority,omitempty" validate:"omitempty,oneof=emerg alert crit err warning notice info debug"`
+	// Matches are "FIELD=value" expressions that all entries must match, e.g. "_COMM=sshd".
+	Matches []string `yaml:"matches,omitempty" validate:"dive,required,contains=="`
+	// ReadFromTail skips the entries written before the receiver first starts.
+	ReadFromTail bool `yaml:"read_from_tail,omitempty"`
+	// Lowercase converts the field names to lowercase.
+	Lowercase bool `yaml:"lowercase,omitempty"`
+	// StripUnderscores removes the leading underscores from the field names.
+	StripUnderscores bool `yaml:"strip_underscores,omitempty"`
 }
 
 func (r LoggingReceiverSystemd) Type() string {
 	return "systemd_journald"
 }
 
+// fieldName returns the name of a journal field in the records, e.g. "comm" for "_COMM" when lowercasing and stripping underscores.
+func (r LoggingReceiverSystemd) fieldName(field string) string {
+	if r.StripUnderscores {
+		field = strings.TrimLeft(field, "_")
+	}
+	if r.Lowercase {
+		field = strings.ToLower(field)
+	}
+	return field
+}
+
 func (r LoggingReceiverSystemd) Components(tag string) []fluentbit.Component {
-	return []fluentbit.Component{{
+	input := fluentbit.Component{
 		Kind: "INPUT",
 		Config: map[string]string{
 			// https://docs.fluentbit.io/manual/pipeline/inputs/systemd
@@ -257,9 +393,317 @@ func (r LoggingReceiverSystemd) Components(tag string) []fluentbit.Component {
 			"Tag":  tag,
 			"DB":   DBPath(tag),
 		},
-	}}
+	}
+	// Filters on the same field are ORed by journald.
+	for _, unit := range r.Units {
+		input.OrderedConfig = append(input.OrderedConfig, [2]string{"Systemd_Filter", "_SYSTEMD_UNIT=" + unit})
+	}
+	if r.ReadFromTail {
+		input.Config["Read_From_Tail"] = "On"
+	}
+	if r.Lowercase {
+		input.Config["Lowercase"] = "On"
+	}
+	if r.StripUnderscores {
+		input.Config["Strip_Underscores"] = "On"
+	}
+	c := []fluentbit.Component{input}
+
+	// Matches and priorities are applied as separate filters, so that they are ANDed with the units.
+	priority := r.fieldName("PRIORITY")
+	if r.Priority != "" {
+		for i, p := range journaldPriorities {
+			if p == r.Priority {
+				c = append(c, fluentbit.Component{
+					Kind: "FILTER",
+					Config: map[string]string{
+						"Name":  "grep",
+						"Match": tag,
+						"Regex": fmt.Sprintf("%s ^[0-%d]$", priority, i),
+					},
+				})
+			}
+		}
+	}
+	for _, m := range r.Matches {
+		kv := strings.SplitN(m, "=", 2)
+		c = append(c, fluentbit.Component{
+			Kind: "FILTER",
+			Config: map[string]string{
+				"Name":  "grep",
+				"Match": tag,
+				"Regex": fmt.Sprintf("%s ^%s$", r.fieldName(kv[0]), regexp.QuoteMeta(kv[1])),
+			},
+		})
+	}
+
+	c = append(c, fluentbit.TranslationComponents(tag, priority, "logging.googleapis.com/severity",
+		[]struct{ SrcVal, DestVal string }{
+			{"0", "EMERGENCY"},
+			{"1", "ALERT"},
+			{"2", "CRITICAL"},
+			{"3", "ERROR"},
+			{"4", "WARNING"},
+			{"5", "NOTICE"},
+			{"6", "INFO"},
+			{"7", "DEBUG"},
+		})...)
+	return c
 }
 
 func init() {
 	LoggingReceiverTypes.RegisterType(func() Component { return &LoggingReceiverSystemd{} }, "linux")
 }
+
+// containerLogsDefaultPaths are the default paths of the container logs, by format.
+var containerLogsDefaultPaths = map[string][]string{
+	"docker": {"/var/lib/docker/containers/*/*-json.log"},
+	"cri":    {"/var/log/containers/*.log"},
+}
+
+// A LoggingReceiverContainerLogs represents the user configuration for a receiver of the logs written by container runtimes.
+type LoggingReceiverContainerLogs struct {
+	ConfigComponent `yaml:",inline"`
+	// Format is the format of the log files: "docker" for Docker's json-file logging driver, or "cri" for containerd and CRI-O.
+	// It defaults to "docker".
+	Format                          string   `yaml:"format,omitempty" validate:"omitempty,oneof=docker cri"`
+	IncludePaths                    []string `yaml:"include_paths,omitempty"`
+	ExcludePaths                    []string `yaml:"exclude_paths,omitempty"`
+	LoggingReceiverShared           `yaml:",inline"`
+	LoggingReceiverFilesTailOptions `yaml:",inline"`
+}
+
+func (r LoggingReceiverContainerLogs) Type() string {
+	return "container_logs"
+}
+
+func (r LoggingReceiverContainerLogs) Components(tag string) []fluentbit.Component {
+	format := r.Format
+	if format == "" {
+		format = "docker"
+	}
+	includePaths := r.IncludePaths
+	if len(includePaths) == 0 {
+		includePaths = containerLogsDefaultPaths[format]
+	}
+	c := LoggingReceiverFilesMixin{
+		IncludePaths:                    includePaths,
+		ExcludePaths:                    r.ExcludePaths,
+		LoggingReceiverFilesTailOptions: r.LoggingReceiverFilesTailOptions,
+	}.Components(tag)
+	// https://docs.fluentbit.io/manual/pipeline/inputs/tail#multiline-core-v1.8
+	// The built-in parsers decode the records and reassemble the lines that were split by the runtime.
+	c[0].Config["multiline.parser"] = format
+	c[0].Config["Path_Key"] = "file"
+	code := fluentbit.LuaCode(`function container_message(tag, timestamp, record)
+		  local message = record["log"]
+		  record["log"] = nil
+		  if type(message) == "string" then
+		    record["message"] = (string.gsub(message, "\n$", ""))
+		  end
+		  return 2, timestamp, record
+		end`)
+	return append(c, fluentbit.LuaFilterComponent(tag, "container_message", code))
+}
+
+// postProcessorComponents adds the container metadata and the severity once the processors have run, so that they
+// are not lost when the records are parsed.
+func (r LoggingReceiverContainerLogs) postProcessorComponents(tag string) []fluentbit.Component {
+	name, _, _ := r.scriptFile()
+	c := []fluentbit.Component{luaScriptFilterComponent(tag, name, "container_metadata")}
+	return append(c, fluentbit.TranslationComponents(tag, "stream", "logging.googleapis.com/severity",
+		[]struct{ SrcVal, DestVal string }{
+			{"stdout", "INFO"},
+			{"stderr", "ERROR"},
+		})...)
+}
+
+// scriptFile returns the name and the content of the file to write the script that adds the container metadata to.
+func (r LoggingReceiverContainerLogs) scriptFile() (string, string, error) {
+	format := r.Format
+	if format == "" {
+		format = "docker"
+	}
+	script := fmt.Sprintf("local format = %s\n%s", fluentbit.LuaQuote(format), containerMetadataLua)
+	return luaScriptName(script), script, nil
+}
+
+// containerMetadataLua adds the container metadata to the labels of the records. It expects the format variable to be
+// defined first.
+// The metadata is read from the path of the log file and, for Docker, from the config.v2.json file of the container.
+// It is cached by file for a minute, so that renamed containers are eventually picked up, and the cache is reset when
+// it holds too many files, so that it doesn't grow with the containers that come and go.
+const containerMetadataLua = `
+local function utf8_char(cp)
+  if cp < 0x80 then return string.char(cp) end
+  if cp < 0x800 then return string.char(0xc0 + math.floor(cp / 0x40), 0x80 + cp % 0x40) end
+  if cp < 0x10000 then
+    return string.char(0xe0 + math.floor(cp / 0x1000), 0x80 + math.floor(cp / 0x40) % 0x40, 0x80 + cp % 0x40)
+  end
+  return string.char(0xf0 + math.floor(cp / 0x40000), 0x80 + math.floor(cp / 0x1000) % 0x40,
+    0x80 + math.floor(cp / 0x40) % 0x40, 0x80 + cp % 0x40)
+end
+local escapes = {['"'] = '"', ['\\'] = '\\', ['/'] = '/', b = '\b', f = '\f', n = '\n', r = '\r', t = '\t'}
+local function decode_json(s)
+  local pos = 1
+  local function fail() error("invalid JSON at " .. pos) end
+  local function skip() pos = string.find(s, "[^ \t\r\n]", pos) or #s + 1 end
+  local function hex4(i)
+    local h = string.sub(s, i, i + 3)
+    if not string.find(h, "^%x%x%x%x$") then fail() end
+    return tonumber(h, 16)
+  end
+  local function str()
+    local out = {}
+    pos = pos + 1
+    while true do
+      local i = string.find(s, '["\\]', pos)
+      if i == nil then fail() end
+      out[#out + 1] = string.sub(s, pos, i - 1)
+      pos = i + 1
+      if string.sub(s, i, i) == '"' then return table.concat(out) end
+      local e = string.sub(s, pos, pos)
+      if e == "u" then
+        local cp = hex4(pos + 1)
+        pos = pos + 5
+        if cp >= 0xd800 and cp < 0xdc00 and string.sub(s, pos, pos + 1) == "\\u" then
+          local lo = hex4(pos + 2)
+          if lo >= 0xdc00 and lo < 0xe000 then
+            cp = 0x10000 + (cp - 0xd800) * 0x400 + (lo - 0xdc00)
+            pos = pos + 6
+          end
+        end
+        out[#out + 1] = utf8_char(cp)
+      elseif escapes[e] then
+        out[#out + 1] = escapes[e]
+        pos = pos + 1
+      else
+        fail()
+      end
+    end
+  end
+  local value
+  local function members(close, member)
+    pos = pos + 1
+    skip()
+    if string.sub(s, pos, pos) == close then
+      pos = pos + 1
+      return
+    end
+    while true do
+      member()
+      skip()
+      local c = string.sub(s, pos, pos)
+      pos = pos + 1
+      if c == close then return end
+      if c ~= "," then fail() end
+    end
+  end
+  value = function()
+    skip()
+    local c = string.sub(s, pos, pos)
+    if c == "{" then
+      local t = {}
+      members("}", function()
+        skip()
+        if string.sub(s, pos, pos) ~= '"' then fail() end
+        local k = str()
+        skip()
+        if string.sub(s, pos, pos) ~= ":" then fail() end
+        pos = pos + 1
+        t[k] = value()
+      end)
+      return t
+    elseif c == "[" then
+      local t, n = {}, 0
+      members("]", function()
+        n = n + 1
+        t[n] = value()
+      end)
+      return t
+    elseif c == '"' then
+      return str()
+    elseif string.sub(s, pos, pos + 3) == "true" then
+      pos = pos + 4
+      return true
+    elseif string.sub(s, pos, pos + 4) == "false" then
+      pos = pos + 5
+      return false
+    elseif string.sub(s, pos, pos + 3) == "null" then
+      pos = pos + 4
+      return nil
+    end
+    local n = string.match(s, "^-?%d+%.?%d*[eE]?[-+]?%d*", pos)
+    if n == nil or tonumber(n) == nil then fail() end
+    pos = pos + #n
+    return tonumber(n)
+  end
+  local ok, v = pcall(function()
+    local v = value()
+    skip()
+    if pos <= #s then fail() end
+    return v
+  end)
+  if ok then return v end
+  return nil
+end
+local function read(path)
+  local f = io.open(path, "r")
+  if f == nil then return nil end
+  local s = f:read("*a")
+  f:close()
+  return s
+end
+local cache = {}
+local cached = 0
+local function metadata(file)
+  local now = os.time()
+  local entry = cache[file]
+  if entry ~= nil and now - entry.time < 60 then return entry.m end
+  if entry == nil then
+    if cached >= 1000 then
+      cache = {}
+      cached = 0
+    end
+    cached = cached + 1
+  end
+  local m = {}
+  if format == "docker" then
+    local dir, id = string.match(file, "^(.*/)(%x+)/[^/]*$")
+    if id then
+      m.container_id = id
+      local config = read(dir .. id .. "/config.v2.json")
+      config = config and decode_json(config)
+      if type(config) == "table" then
+        if type(config.Name) == "string" then m.container_name = (string.gsub(config.Name, "^/", "")) end
+        if type(config.Config) == "table" and type(config.Config.Image) == "string" then
+          m.container_image = config.Config.Image
+        end
+      end
+    end
+  else
+    local pod, namespace, name, id = string.match(file, "([^/_]+)_([^/_]+)_([^/]+)%-(%x+)%.log$")
+    if id then
+      m.container_id = id
+      m.container_name = name
+      m.pod_name = pod
+      m.namespace_name = namespace
+    end
+  end
+  cache[file] = {m = m, time = now}
+  return m
+end
+function container_metadata(tag, timestamp, record)
+  local file = record["file"]
+  record["file"] = nil
+  if type(file) ~= "string" then return 2, timestamp, record end
+  local labels = record["logging.googleapis.com/labels"] or {}
+  for k, v in pairs(metadata(file)) do labels[k] = v end
+  if next(labels) ~= nil then record["logging.googleapis.com/labels"] = labels end
+  return 2, timestamp, record
+end
+`
+
+func init() {
+	LoggingReceiverTypes.RegisterType(func() Component { return &LoggingReceiverContainerLogs{} }, "linux")
+}
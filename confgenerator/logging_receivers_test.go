@@ -0,0 +1,175 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confgenerator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// dockerContainer is a container in a fake Docker data root.
+type dockerContainer struct {
+	t  *testing.T
+	id string
+	// logPath is the path of the log file of the container.
+	logPath string
+}
+
+func newDockerContainer(t *testing.T, root, id string) dockerContainer {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(root, id), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dockerContainer{t, id, filepath.Join(root, id, id+"-json.log")}
+}
+
+// setConfig writes the config.v2.json file of the container.
+func (c dockerContainer) setConfig(config string) {
+	c.t.Helper()
+	if err := ioutil.WriteFile(filepath.Join(filepath.Dir(c.logPath), "config.v2.json"), []byte(config), 0644); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// containerMetadataState returns a Lua state running the container metadata script of r, where os.time returns the
+// global variable "now".
+func containerMetadataState(t *testing.T, r LoggingReceiverContainerLogs) *lua.LState {
+	t.Helper()
+	L := newLuaState(t)
+	_, script, _ := r.scriptFile()
+	if err := L.DoString("now = 0\nos.time = function() return now end\n" + script); err != nil {
+		t.Fatalf("failed to run script: %v", err)
+	}
+	return L
+}
+
+// containerLabels calls container_metadata on a record read from file and returns its labels.
+func containerLabels(t *testing.T, L *lua.LState, file string) map[string]string {
+	t.Helper()
+	record := L.NewTable()
+	record.RawSetString("file", lua.LString(file))
+	if err := L.CallByParam(lua.P{Fn: L.GetGlobal("container_metadata"), NRet: 3, Protect: true}, lua.LString("tag"), lua.LNumber(0), record); err != nil {
+		t.Fatalf("container_metadata() failed: %v", err)
+	}
+	L.Pop(3)
+	if record.RawGetString("file") != lua.LNil {
+		t.Errorf("container_metadata() kept the file field")
+	}
+	labels := map[string]string{}
+	if table, ok := record.RawGetString("logging.googleapis.com/labels").(*lua.LTable); ok {
+		table.ForEach(func(k, v lua.LValue) { labels[k.String()] = v.String() })
+	}
+	return labels
+}
+
+func TestContainerMetadata(t *testing.T) {
+	root := t.TempDir()
+	for _, tc := range []struct {
+		name   string
+		config string
+		want   map[string]string
+	}{
+		{
+			name:   "compact",
+			config: `{"StreamConfig":{},"State":{"Running":true},"ID":"0123abcd","Config":{"Hostname":"0123abcd","Labels":{"com.example.image":"fake"},"Image":"nginx:1.21"},"LogPath":"/x","Name":"/web"}`,
+			want:   map[string]string{"container_name": "web", "container_image": "nginx:1.21"},
+		},
+		{
+			name: "indented with escapes",
+			config: `{
+			  "Name": "\/café😀",
+			  "Config": {"Env": ["A=\"b\"", null, 1.5e3, true, false, [], {}], "Image": "registry.example.com:5000/app@sha256:abc"}
+			}`,
+			want: map[string]string{"container_name": "café\U0001F600", "container_image": "registry.example.com:5000/app@sha256:abc"},
+		},
+		{
+			name:   "invalid",
+			config: `{"Name":"/web","Config":{"Image":"nginx"}`,
+			want:   map[string]string{},
+		},
+		{
+			name:   "unexpected types",
+			config: `{"Name":1,"Config":"nginx"}`,
+			want:   map[string]string{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newDockerContainer(t, root, fmt.Sprintf("%064x", len(tc.name)))
+			c.setConfig(tc.config)
+			L := containerMetadataState(t, LoggingReceiverContainerLogs{})
+			tc.want["container_id"] = c.id
+			if diff := cmp.Diff(tc.want, containerLabels(t, L, c.logPath)); diff != "" {
+				t.Errorf("container_metadata() labels mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestContainerMetadataCRI(t *testing.T) {
+	L := containerMetadataState(t, LoggingReceiverContainerLogs{Format: "cri"})
+	want := map[string]string{
+		"container_id":   "0123abcd",
+		"container_name": "istio-proxy",
+		"pod_name":       "web-5d8f7",
+		"namespace_name": "default",
+	}
+	if diff := cmp.Diff(want, containerLabels(t, L, "/var/log/containers/web-5d8f7_default_istio-proxy-0123abcd.log")); diff != "" {
+		t.Errorf("container_metadata() labels mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestContainerMetadataCache(t *testing.T) {
+	root := t.TempDir()
+	c := newDockerContainer(t, root, fmt.Sprintf("%064x", 1))
+	L := containerMetadataState(t, LoggingReceiverContainerLogs{})
+	name := func() string {
+		t.Helper()
+		return containerLabels(t, L, c.logPath)["container_name"]
+	}
+	setNow := func(now int) { L.SetGlobal("now", lua.LNumber(now)) }
+
+	c.setConfig(`{"Name":"/old"}`)
+	if got := name(); got != "old" {
+		t.Fatalf("container name = %q, want %q", got, "old")
+	}
+	c.setConfig(`{"Name":"/new"}`)
+	setNow(59)
+	if got := name(); got != "old" {
+		t.Errorf("container name after 59s = %q, want the cached %q", got, "old")
+	}
+	setNow(60)
+	if got := name(); got != "new" {
+		t.Errorf("container name after 60s = %q, want %q", got, "new")
+	}
+
+	// Fill the cache up to its size bound with other files. The cached name is kept until one more file is added.
+	c.setConfig(`{"Name":"/newer"}`)
+	for i := 2; i <= 1000; i++ {
+		containerLabels(t, L, filepath.Join(root, fmt.Sprintf("%064x", i), "container-json.log"))
+	}
+	if got := name(); got != "new" {
+		t.Errorf("container name with a full cache = %q, want the cached %q", got, "new")
+	}
+	containerLabels(t, L, filepath.Join(root, fmt.Sprintf("%064x", 1001), "container-json.log"))
+	if got := name(); got != "newer" {
+		t.Errorf("container name after the cache was reset = %q, want %q", got, "newer")
+	}
+}
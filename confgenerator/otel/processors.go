@@ -94,6 +94,19 @@ func RenameMetric(old, new string, operations ...map[string]interface{}) map[str
 	return out
 }
 
+// UpdateMetricsRegexp returns a config snippet that applies zero or more transformations to the metrics matching the regex include.
+func UpdateMetricsRegexp(include string, operations ...map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{
+		"include":    include,
+		"match_type": "regexp",
+		"action":     "update",
+	}
+	if len(operations) > 0 {
+		out["operations"] = operations
+	}
+	return out
+}
+
 // DuplicateMetric returns a config snippet that copies old to new, applying zero or more transformations.
 func DuplicateMetric(old, new string, operations ...map[string]interface{}) map[string]interface{} {
 	out := map[string]interface{}{
@@ -0,0 +1,9 @@
+-- Removes the query string from the "url" field.
+function normalize_url(tag, timestamp, record)
+  local url = record["url"]
+  if type(url) ~= "string" then
+    return 0, timestamp, record
+  end
+  record["url"] = url:gsub("%?.*$", "")
+  return 2, timestamp, record
+end
@@ -0,0 +1,113 @@
+local detectors = {{"eyJ[%w%-_]+%.[%w%-_]+%.[%w%-_]+", ""}, {"[Bb]earer%s+[%w%-%._~%+/]+=*", ""}, {"[%w%.%%%+%-_]+@[%w%.%-]+%.%a%a+", ""}, {"%d[%d%- ]+%d", "cards"}, {"%d+%.%d+%.%d+%.%d+", "ipv4"}, {"%x*:[%x:]*%x", "ipv6"}}
+local fields = nil
+local action = "mask"
+
+local function luhn(m)
+  local d = m:gsub("%D", "")
+  if #d < 13 or #d > 19 then return false end
+  local sum = 0
+  local alt = false
+  for i = #d, 1, -1 do
+    local n = tonumber(d:sub(i, i))
+    if alt then n = n * 2 if n > 9 then n = n - 9 end end
+    sum = sum + n
+    alt = not alt
+  end
+  return sum % 10 == 0
+end
+local function ipv4(m)
+  for o in m:gmatch("%d+") do
+    if #o > 3 or tonumber(o) > 255 then return false end
+  end
+  return true
+end
+local function ipv6(m)
+  local _, colons = m:gsub(":", "")
+  if colons < 2 or colons > 7 then return false end
+  if colons < 7 and not m:find("::", 1, true) then return false end
+  local groups = 0
+  for g in m:gmatch("[^:]+") do
+    if #g > 4 then return false end
+    groups = groups + 1
+  end
+  return groups >= 2
+end
+local checks = {ipv4 = ipv4, ipv6 = ipv6}
+local function replacement(m)
+  if action == "hash" then return "[REDACTED:" .. hex(hmac_sha256(hash_key, m)):sub(1, 16) .. "]" end
+  return "[REDACTED]"
+end
+local function isdigit(s, i)
+  local c = s:byte(i)
+  return c ~= nil and c >= 48 and c <= 57
+end
+local function cards(m)
+  local out, n, i = {}, 0, 1
+  while i <= #m do
+    local last
+    if isdigit(m, i) and not isdigit(m, i - 1) then
+      local ends, digits, k = {}, 0, i
+      while k <= #m and digits < 19 do
+        if isdigit(m, k) then
+          digits = digits + 1
+          if digits >= 13 and not isdigit(m, k + 1) then ends[#ends + 1] = k end
+        end
+        k = k + 1
+      end
+      for e = #ends, 1, -1 do
+        if luhn(m:sub(i, ends[e])) then last = ends[e] break end
+      end
+    end
+    if last then
+      out[#out + 1] = replacement(m:sub(i, last))
+      n = n + 1
+      i = last + 1
+    else
+      out[#out + 1] = m:sub(i, i)
+      i = i + 1
+    end
+  end
+  return table.concat(out), n
+end
+local scanners = {cards = cards}
+local function redact_string(s)
+  local total = 0
+  for _, d in ipairs(detectors) do
+    s = s:gsub(d[1], function(m)
+      local scan = scanners[d[2]]
+      if scan then
+        local r, n = scan(m)
+        total = total + n
+        return r
+      end
+      if d[2] == "" or checks[d[2]](m) then
+        total = total + 1
+        return replacement(m)
+      end
+    end)
+  end
+  return s, total
+end
+local changed = false
+local function visit(t, k)
+  local v = t[k]
+  if type(v) == "string" then
+    local s, n = redact_string(v)
+    if n > 0 then
+      changed = true
+      if action == "drop_field" then t[k] = nil else t[k] = s end
+    end
+  elseif type(v) == "table" then
+    for kk in pairs(v) do visit(v, kk) end
+  end
+end
+function redact(tag, timestamp, record)
+  changed = false
+  if fields then
+    for _, f in ipairs(fields) do visit(record, f) end
+  else
+    for k in pairs(record) do visit(record, k) end
+  end
+  if changed then return 2, timestamp, record end
+  return 0, timestamp, record
+end
@@ -0,0 +1,170 @@
+local format = "cri"
+
+local function utf8_char(cp)
+  if cp < 0x80 then return string.char(cp) end
+  if cp < 0x800 then return string.char(0xc0 + math.floor(cp / 0x40), 0x80 + cp % 0x40) end
+  if cp < 0x10000 then
+    return string.char(0xe0 + math.floor(cp / 0x1000), 0x80 + math.floor(cp / 0x40) % 0x40, 0x80 + cp % 0x40)
+  end
+  return string.char(0xf0 + math.floor(cp / 0x40000), 0x80 + math.floor(cp / 0x1000) % 0x40,
+    0x80 + math.floor(cp / 0x40) % 0x40, 0x80 + cp % 0x40)
+end
+local escapes = {['"'] = '"', ['\\'] = '\\', ['/'] = '/', b = '\b', f = '\f', n = '\n', r = '\r', t = '\t'}
+local function decode_json(s)
+  local pos = 1
+  local function fail() error("invalid JSON at " .. pos) end
+  local function skip() pos = string.find(s, "[^ \t\r\n]", pos) or #s + 1 end
+  local function hex4(i)
+    local h = string.sub(s, i, i + 3)
+    if not string.find(h, "^%x%x%x%x$") then fail() end
+    return tonumber(h, 16)
+  end
+  local function str()
+    local out = {}
+    pos = pos + 1
+    while true do
+      local i = string.find(s, '["\\]', pos)
+      if i == nil then fail() end
+      out[#out + 1] = string.sub(s, pos, i - 1)
+      pos = i + 1
+      if string.sub(s, i, i) == '"' then return table.concat(out) end
+      local e = string.sub(s, pos, pos)
+      if e == "u" then
+        local cp = hex4(pos + 1)
+        pos = pos + 5
+        if cp >= 0xd800 and cp < 0xdc00 and string.sub(s, pos, pos + 1) == "\\u" then
+          local lo = hex4(pos + 2)
+          if lo >= 0xdc00 and lo < 0xe000 then
+            cp = 0x10000 + (cp - 0xd800) * 0x400 + (lo - 0xdc00)
+            pos = pos + 6
+          end
+        end
+        out[#out + 1] = utf8_char(cp)
+      elseif escapes[e] then
+        out[#out + 1] = escapes[e]
+        pos = pos + 1
+      else
+        fail()
+      end
+    end
+  end
+  local value
+  local function members(close, member)
+    pos = pos + 1
+    skip()
+    if string.sub(s, pos, pos) == close then
+      pos = pos + 1
+      return
+    end
+    while true do
+      member()
+      skip()
+      local c = string.sub(s, pos, pos)
+      pos = pos + 1
+      if c == close then return end
+      if c ~= "," then fail() end
+    end
+  end
+  value = function()
+    skip()
+    local c = string.sub(s, pos, pos)
+    if c == "{" then
+      local t = {}
+      members("}", function()
+        skip()
+        if string.sub(s, pos, pos) ~= '"' then fail() end
+        local k = str()
+        skip()
+        if string.sub(s, pos, pos) ~= ":" then fail() end
+        pos = pos + 1
+        t[k] = value()
+      end)
+      return t
+    elseif c == "[" then
+      local t, n = {}, 0
+      members("]", function()
+        n = n + 1
+        t[n] = value()
+      end)
+      return t
+    elseif c == '"' then
+      return str()
+    elseif string.sub(s, pos, pos + 3) == "true" then
+      pos = pos + 4
+      return true
+    elseif string.sub(s, pos, pos + 4) == "false" then
+      pos = pos + 5
+      return false
+    elseif string.sub(s, pos, pos + 3) == "null" then
+      pos = pos + 4
+      return nil
+    end
+    local n = string.match(s, "^-?%d+%.?%d*[eE]?[-+]?%d*", pos)
+    if n == nil or tonumber(n) == nil then fail() end
+    pos = pos + #n
+    return tonumber(n)
+  end
+  local ok, v = pcall(function()
+    local v = value()
+    skip()
+    if pos <= #s then fail() end
+    return v
+  end)
+  if ok then return v end
+  return nil
+end
+local function read(path)
+  local f = io.open(path, "r")
+  if f == nil then return nil end
+  local s = f:read("*a")
+  f:close()
+  return s
+end
+local cache = {}
+local cached = 0
+local function metadata(file)
+  local now = os.time()
+  local entry = cache[file]
+  if entry ~= nil and now - entry.time < 60 then return entry.m end
+  if entry == nil then
+    if cached >= 1000 then
+      cache = {}
+      cached = 0
+    end
+    cached = cached + 1
+  end
+  local m = {}
+  if format == "docker" then
+    local dir, id = string.match(file, "^(.*/)(%x+)/[^/]*$")
+    if id then
+      m.container_id = id
+      local config = read(dir .. id .. "/config.v2.json")
+      config = config and decode_json(config)
+      if type(config) == "table" then
+        if type(config.Name) == "string" then m.container_name = (string.gsub(config.Name, "^/", "")) end
+        if type(config.Config) == "table" and type(config.Config.Image) == "string" then
+          m.container_image = config.Config.Image
+        end
+      end
+    end
+  else
+    local pod, namespace, name, id = string.match(file, "([^/_]+)_([^/_]+)_([^/]+)%-(%x+)%.log$")
+    if id then
+      m.container_id = id
+      m.container_name = name
+      m.pod_name = pod
+      m.namespace_name = namespace
+    end
+  end
+  cache[file] = {m = m, time = now}
+  return m
+end
+function container_metadata(tag, timestamp, record)
+  local file = record["file"]
+  record["file"] = nil
+  if type(file) ~= "string" then return 2, timestamp, record end
+  local labels = record["logging.googleapis.com/labels"] or {}
+  for k, v in pairs(metadata(file)) do labels[k] = v end
+  if next(labels) ~= nil then record["logging.googleapis.com/labels"] = labels end
+  return 2, timestamp, record
+end
@@ -0,0 +1,6 @@
+function latency_bucket(tag, timestamp, record)
+  local ms = tonumber(record["latency_ms"])
+  if ms == nil then return 0, timestamp, record end
+  if ms < 100 then record["latency_bucket"] = "fast" else record["latency_bucket"] = "slow" end
+  return 2, timestamp, record
+end
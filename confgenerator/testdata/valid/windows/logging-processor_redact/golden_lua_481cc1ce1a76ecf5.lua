@@ -0,0 +1,168 @@
+local detectors = {{"[%w%.%%%+%-_]+@[%w%.%-]+%.%a%a+", ""}, {"%d[%d%- ]+%d", "cards"}, {"ssn=%d%d%d%-%d%d%-%d%d%d%d", ""}, {"^acct%-[%u%d]+$", ""}}
+local fields = {"message", "user"}
+local action = "hash"
+local hash_key = "9f2c4e7a1b8d3f60"
+
+local band, bor, bxor, bnot, lshift, rshift, ror, tobit = bit.band, bit.bor, bit.bxor, bit.bnot, bit.lshift, bit.rshift, bit.ror, bit.tobit
+local K = {
+  0x428a2f98, 0x71374491, 0xb5c0fbcf, 0xe9b5dba5, 0x3956c25b, 0x59f111f1, 0x923f82a4, 0xab1c5ed5,
+  0xd807aa98, 0x12835b01, 0x243185be, 0x550c7dc3, 0x72be5d74, 0x80deb1fe, 0x9bdc06a7, 0xc19bf174,
+  0xe49b69c1, 0xefbe4786, 0x0fc19dc6, 0x240ca1cc, 0x2de92c6f, 0x4a7484aa, 0x5cb0a9dc, 0x76f988da,
+  0x983e5152, 0xa831c66d, 0xb00327c8, 0xbf597fc7, 0xc6e00bf3, 0xd5a79147, 0x06ca6351, 0x14292967,
+  0x27b70a85, 0x2e1b2138, 0x4d2c6dfc, 0x53380d13, 0x650a7354, 0x766a0abb, 0x81c2c92e, 0x92722c85,
+  0xa2bfe8a1, 0xa81a664b, 0xc24b8b70, 0xc76c51a3, 0xd192e819, 0xd6990624, 0xf40e3585, 0x106aa070,
+  0x19a4c116, 0x1e376c08, 0x2748774c, 0x34b0bcb5, 0x391c0cb3, 0x4ed8aa4a, 0x5b9cca4f, 0x682e6ff3,
+  0x748f82ee, 0x78a5636f, 0x84c87814, 0x8cc70208, 0x90befffa, 0xa4506ceb, 0xbef9a3f7, 0xc67178f2,
+}
+local function be32(n)
+  return string.char(band(rshift(n, 24), 255), band(rshift(n, 16), 255), band(rshift(n, 8), 255), band(n, 255))
+end
+local function sha256(msg)
+  local H = {0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19}
+  local bits = #msg * 8
+  msg = msg .. "\128" .. string.rep("\0", (55 - #msg) % 64) .. be32(math.floor(bits / 4294967296)) .. be32(bits % 4294967296)
+  local w = {}
+  for i = 1, #msg, 64 do
+    for j = 0, 15 do
+      local b1, b2, b3, b4 = msg:byte(i + j * 4, i + j * 4 + 3)
+      w[j] = bor(lshift(b1, 24), lshift(b2, 16), lshift(b3, 8), b4)
+    end
+    for j = 16, 63 do
+      local s0 = bxor(ror(w[j - 15], 7), ror(w[j - 15], 18), rshift(w[j - 15], 3))
+      local s1 = bxor(ror(w[j - 2], 17), ror(w[j - 2], 19), rshift(w[j - 2], 10))
+      w[j] = tobit(w[j - 16] + s0 + w[j - 7] + s1)
+    end
+    local a, b, c, d, e, f, g, h = H[1], H[2], H[3], H[4], H[5], H[6], H[7], H[8]
+    for j = 0, 63 do
+      local t1 = h + bxor(ror(e, 6), ror(e, 11), ror(e, 25)) + bxor(band(e, f), band(bnot(e), g)) + K[j + 1] + w[j]
+      local t2 = bxor(ror(a, 2), ror(a, 13), ror(a, 22)) + bxor(band(a, b), band(a, c), band(b, c))
+      h, g, f, e, d, c, b, a = g, f, e, tobit(d + t1), c, b, a, tobit(t1 + t2)
+    end
+    H[1], H[2], H[3], H[4] = tobit(H[1] + a), tobit(H[2] + b), tobit(H[3] + c), tobit(H[4] + d)
+    H[5], H[6], H[7], H[8] = tobit(H[5] + e), tobit(H[6] + f), tobit(H[7] + g), tobit(H[8] + h)
+  end
+  local out = {}
+  for i = 1, 8 do out[i] = be32(H[i]) end
+  return table.concat(out)
+end
+local function hmac_sha256(key, msg)
+  if #key > 64 then key = sha256(key) end
+  key = key .. string.rep("\0", 64 - #key)
+  local ipad = key:gsub(".", function(ch) return string.char(bxor(ch:byte(), 0x36)) end)
+  local opad = key:gsub(".", function(ch) return string.char(bxor(ch:byte(), 0x5c)) end)
+  return sha256(opad .. sha256(ipad .. msg))
+end
+local function hex(s)
+  return (s:gsub(".", function(ch) return string.format("%02x", ch:byte()) end))
+end
+
+
+local function luhn(m)
+  local d = m:gsub("%D", "")
+  if #d < 13 or #d > 19 then return false end
+  local sum = 0
+  local alt = false
+  for i = #d, 1, -1 do
+    local n = tonumber(d:sub(i, i))
+    if alt then n = n * 2 if n > 9 then n = n - 9 end end
+    sum = sum + n
+    alt = not alt
+  end
+  return sum % 10 == 0
+end
+local function ipv4(m)
+  for o in m:gmatch("%d+") do
+    if #o > 3 or tonumber(o) > 255 then return false end
+  end
+  return true
+end
+local function ipv6(m)
+  local _, colons = m:gsub(":", "")
+  if colons < 2 or colons > 7 then return false end
+  if colons < 7 and not m:find("::", 1, true) then return false end
+  local groups = 0
+  for g in m:gmatch("[^:]+") do
+    if #g > 4 then return false end
+    groups = groups + 1
+  end
+  return groups >= 2
+end
+local checks = {ipv4 = ipv4, ipv6 = ipv6}
+local function replacement(m)
+  if action == "hash" then return "[REDACTED:" .. hex(hmac_sha256(hash_key, m)):sub(1, 16) .. "]" end
+  return "[REDACTED]"
+end
+local function isdigit(s, i)
+  local c = s:byte(i)
+  return c ~= nil and c >= 48 and c <= 57
+end
+local function cards(m)
+  local out, n, i = {}, 0, 1
+  while i <= #m do
+    local last
+    if isdigit(m, i) and not isdigit(m, i - 1) then
+      local ends, digits, k = {}, 0, i
+      while k <= #m and digits < 19 do
+        if isdigit(m, k) then
+          digits = digits + 1
+          if digits >= 13 and not isdigit(m, k + 1) then ends[#ends + 1] = k end
+        end
+        k = k + 1
+      end
+      for e = #ends, 1, -1 do
+        if luhn(m:sub(i, ends[e])) then last = ends[e] break end
+      end
+    end
+    if last then
+      out[#out + 1] = replacement(m:sub(i, last))
+      n = n + 1
+      i = last + 1
+    else
+      out[#out + 1] = m:sub(i, i)
+      i = i + 1
+    end
+  end
+  return table.concat(out), n
+end
+local scanners = {cards = cards}
+local function redact_string(s)
+  local total = 0
+  for _, d in ipairs(detectors) do
+    s = s:gsub(d[1], function(m)
+      local scan = scanners[d[2]]
+      if scan then
+        local r, n = scan(m)
+        total = total + n
+        return r
+      end
+      if d[2] == "" or checks[d[2]](m) then
+        total = total + 1
+        return replacement(m)
+      end
+    end)
+  end
+  return s, total
+end
+local changed = false
+local function visit(t, k)
+  local v = t[k]
+  if type(v) == "string" then
+    local s, n = redact_string(v)
+    if n > 0 then
+      changed = true
+      if action == "drop_field" then t[k] = nil else t[k] = s end
+    end
+  elseif type(v) == "table" then
+    for kk in pairs(v) do visit(v, kk) end
+  end
+end
+function redact(tag, timestamp, record)
+  changed = false
+  if fields then
+    for _, f in ipairs(fields) do visit(record, f) end
+  else
+    for k in pairs(record) do visit(record, k) end
+  end
+  if changed then return 2, timestamp, record end
+  return 0, timestamp, record
+end
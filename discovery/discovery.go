@@ -0,0 +1,274 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package discovery finds applications running on a host and proposes receivers for them.
+package discovery
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator"
+)
+
+// Process is a process running on the host.
+type Process struct {
+	PID  int32
+	PPID int32
+	// Name is the executable name, e.g. "redis-server".
+	Name    string
+	Cmdline []string
+}
+
+// Arg returns the value of the first command line argument starting with prefix, e.g. "-Dcom.sun.management.jmxremote.port=".
+func (p Process) Arg(prefix string) (string, bool) {
+	for _, arg := range p.Cmdline {
+		if strings.HasPrefix(arg, prefix) {
+			return strings.TrimPrefix(arg, prefix), true
+		}
+	}
+	return "", false
+}
+
+// HasArg reports whether any command line argument is exactly arg.
+func (p Process) HasArg(arg string) bool {
+	for _, a := range p.Cmdline {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// Host is a snapshot of what is running on a host.
+type Host struct {
+	Hostname  string
+	Processes []Process
+	// ListeningPorts maps a PID to the TCP ports the process listens on.
+	ListeningPorts map[int32][]int
+	// Glob returns the paths matching pattern, with the semantics of filepath.Glob.
+	Glob func(pattern string) ([]string, error)
+}
+
+// Detection is an instance of an application found on the host.
+type Detection struct {
+	Process Process
+	// Ports are the TCP ports the application listens on, sorted.
+	Ports []int
+}
+
+// Port returns preferred if the application listens on it, otherwise the lowest port the application listens on.
+// preferred is also returned if the application does not listen on any port, or if the listening ports are unknown.
+func (d Detection) Port(preferred int) int {
+	for _, p := range d.Ports {
+		if p == preferred {
+			return p
+		}
+	}
+	if len(d.Ports) > 0 {
+		return d.Ports[0]
+	}
+	return preferred
+}
+
+// LogSource is a logging receiver that is proposed when one of its well-known log paths exists.
+type LogSource struct {
+	// Type is the logging receiver type, e.g. "apache_access".
+	Type string
+	// Paths are the well-known log path patterns. "${HOSTNAME}" is expanded to the host name when looking for files.
+	Paths []string
+	// Receiver returns the logging receiver reading includePaths, the subset of Paths that exist on the host.
+	Receiver func(includePaths []string) confgenerator.LoggingReceiver
+}
+
+// A Rule recognizes an application and proposes receivers for it.
+type Rule struct {
+	// Name identifies the application, e.g. "redis". It is used in the IDs of the proposed receivers and pipelines.
+	Name string
+	// Match reports whether the process belongs to the application.
+	Match func(p Process) bool
+	// Fallback rules are only matched against processes that no other rule matched, e.g. a generic JVM rule.
+	Fallback bool
+	// MetricsGroup is set on rules whose metrics receivers conflict with each other.
+	// At most one metrics receiver is proposed across all rules with the same non-empty MetricsGroup.
+	MetricsGroup string
+	// MetricsReceiver returns the metrics receiver for an instance of the application. It may be nil.
+	MetricsReceiver func(d Detection) confgenerator.MetricsReceiver
+	// Logs are the logging receivers to propose when the application is running.
+	Logs []LogSource
+}
+
+type ruleRegistry struct {
+	rules []Rule
+}
+
+// Register adds a rule. It is meant to be called from init functions.
+func (r *ruleRegistry) Register(rule Rule) {
+	r.rules = append(r.rules, rule)
+}
+
+// ordered returns the rules sorted by name, with fallback rules last.
+func (r *ruleRegistry) ordered() []Rule {
+	rules := append([]Rule{}, r.rules...)
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Fallback != rules[j].Fallback {
+			return !rules[i].Fallback
+		}
+		return rules[i].Name < rules[j].Name
+	})
+	return rules
+}
+
+// Rules holds the rules registered by the apps package.
+var Rules = &ruleRegistry{}
+
+// Discover inspects the current host and proposes a config for the applications running on it.
+func Discover() (*confgenerator.UnifiedConfig, error) {
+	h, err := Inspect()
+	if err != nil {
+		return nil, err
+	}
+	return Propose(h), nil
+}
+
+// Propose returns a config with receivers for the applications running on h.
+// Each application gets a metrics and a logging pipeline named "discovered_<name>".
+func Propose(h *Host) *confgenerator.UnifiedConfig {
+	uc := &confgenerator.UnifiedConfig{
+		Logging: &confgenerator.Logging{
+			Receivers:  map[string]confgenerator.LoggingReceiver{},
+			Processors: map[string]confgenerator.LoggingProcessor{},
+			Service: &confgenerator.LoggingService{
+				Pipelines: map[string]*confgenerator.LoggingPipeline{},
+			},
+		},
+		Metrics: &confgenerator.Metrics{
+			Receivers:  map[string]confgenerator.MetricsReceiver{},
+			Processors: map[string]confgenerator.MetricsProcessor{},
+			Service: &confgenerator.MetricsService{
+				Pipelines: map[string]*confgenerator.MetricsPipeline{},
+			},
+		},
+	}
+	claimed := map[int32]bool{}
+	usedGroups := map[string]bool{}
+	for _, rule := range Rules.ordered() {
+		detections := detect(h, rule, claimed)
+		if len(detections) == 0 {
+			continue
+		}
+		pipelineID := "discovered_" + rule.Name
+
+		if rule.MetricsReceiver != nil && !usedGroups[rule.MetricsGroup] {
+			if rule.MetricsGroup != "" {
+				// Only the first instance can be monitored.
+				detections = detections[:1]
+				usedGroups[rule.MetricsGroup] = true
+			}
+			var ids []string
+			for i, d := range detections {
+				id := pipelineID
+				if i > 0 {
+					id = fmt.Sprintf("%s_%d", pipelineID, i+1)
+				}
+				uc.Metrics.Receivers[id] = rule.MetricsReceiver(d)
+				ids = append(ids, id)
+			}
+			uc.Metrics.Service.Pipelines[pipelineID] = &confgenerator.MetricsPipeline{
+				ReceiverIDs: ids,
+			}
+		}
+
+		var ids []string
+		for _, source := range rule.Logs {
+			paths := existingPaths(h, source.Paths)
+			if len(paths) == 0 {
+				continue
+			}
+			id := "discovered_" + source.Type
+			uc.Logging.Receivers[id] = source.Receiver(paths)
+			ids = append(ids, id)
+		}
+		if len(ids) > 0 {
+			uc.Logging.Service.Pipelines[pipelineID] = &confgenerator.LoggingPipeline{
+				ReceiverIDs: ids,
+			}
+		}
+	}
+	return uc
+}
+
+// detect returns one detection per instance of the application, and marks their processes as claimed.
+// Processes whose parent also matches the rule (e.g. worker processes) are folded into their parent's instance.
+func detect(h *Host, rule Rule, claimed map[int32]bool) []Detection {
+	matched := map[int32]Process{}
+	for _, p := range h.Processes {
+		if claimed[p.PID] || !rule.Match(p) {
+			continue
+		}
+		matched[p.PID] = p
+	}
+	root := func(p Process) Process {
+		for {
+			parent, ok := matched[p.PPID]
+			if !ok || parent.PID == p.PID {
+				return p
+			}
+			p = parent
+		}
+	}
+	ports := map[int32]map[int]bool{}
+	for _, p := range matched {
+		claimed[p.PID] = true
+		r := root(p)
+		if ports[r.PID] == nil {
+			ports[r.PID] = map[int]bool{}
+		}
+		for _, port := range h.ListeningPorts[p.PID] {
+			ports[r.PID][port] = true
+		}
+	}
+	var detections []Detection
+	for pid, portSet := range ports {
+		d := Detection{Process: matched[pid]}
+		for port := range portSet {
+			d.Ports = append(d.Ports, port)
+		}
+		sort.Ints(d.Ports)
+		detections = append(detections, d)
+	}
+	sort.Slice(detections, func(i, j int) bool {
+		return detections[i].Process.PID < detections[j].Process.PID
+	})
+	return detections
+}
+
+// existingPaths returns the patterns that match at least one file on h.
+func existingPaths(h *Host, patterns []string) []string {
+	var out []string
+	for _, pattern := range patterns {
+		expanded := os.Expand(pattern, func(name string) string {
+			if name == "HOSTNAME" {
+				return h.Hostname
+			}
+			return "$" + name
+		})
+		if matches, err := h.Glob(expanded); err == nil && len(matches) > 0 {
+			out = append(out, pattern)
+		}
+	}
+	return out
+}
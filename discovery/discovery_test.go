@@ -0,0 +1,188 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/ops-agent/apps"
+	"github.com/GoogleCloudPlatform/ops-agent/confgenerator"
+	"github.com/GoogleCloudPlatform/ops-agent/discovery"
+)
+
+// newHost returns a host running processes, where existingFiles are the only files that exist.
+func newHost(processes []discovery.Process, ports map[int32][]int, existingFiles ...string) *discovery.Host {
+	return &discovery.Host{
+		Hostname:       "test-host",
+		Processes:      processes,
+		ListeningPorts: ports,
+		Glob: func(pattern string) ([]string, error) {
+			for _, f := range existingFiles {
+				if f == pattern {
+					return []string{f}, nil
+				}
+			}
+			return nil, nil
+		},
+	}
+}
+
+func metricsReceiverIDs(uc *confgenerator.UnifiedConfig) []string {
+	var ids []string
+	for id := range uc.Metrics.Receivers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestProcessArg(t *testing.T) {
+	p := discovery.Process{Cmdline: []string{"java", "-Xmx1g", "-Dcom.sun.management.jmxremote.port=9010", "-jar", "app.jar"}}
+	if v, ok := p.Arg("-Dcom.sun.management.jmxremote.port="); !ok || v != "9010" {
+		t.Errorf("Arg() = %q, %v; want \"9010\", true", v, ok)
+	}
+	if _, ok := p.Arg("-Dcassandra.jmx.local.port="); ok {
+		t.Errorf("Arg() found a flag that is not on the command line")
+	}
+	if !p.HasArg("-jar") || p.HasArg("-ja") {
+		t.Errorf("HasArg() must only match whole arguments")
+	}
+}
+
+func TestDetectionPort(t *testing.T) {
+	for _, tc := range []struct {
+		ports     []int
+		preferred int
+		want      int
+	}{
+		{nil, 6379, 6379},
+		{[]int{6380}, 6379, 6380},
+		{[]int{6379, 16379}, 6379, 6379},
+		{[]int{443, 8080}, 80, 443},
+	} {
+		d := discovery.Detection{Ports: tc.ports}
+		if got := d.Port(tc.preferred); got != tc.want {
+			t.Errorf("Detection{Ports: %v}.Port(%d) = %d, want %d", tc.ports, tc.preferred, got, tc.want)
+		}
+	}
+}
+
+func TestProposeListeningPorts(t *testing.T) {
+	h := newHost([]discovery.Process{
+		{PID: 100, PPID: 1, Name: "apache2", Cmdline: []string{"/usr/sbin/apache2", "-k", "start"}},
+		{PID: 101, PPID: 100, Name: "apache2", Cmdline: []string{"/usr/sbin/apache2", "-k", "start"}},
+		{PID: 200, PPID: 1, Name: "redis-server", Cmdline: []string{"/usr/bin/redis-server", "127.0.0.1:6380"}},
+	}, map[int32][]int{
+		// The port of a worker is attributed to the parent process.
+		101: {8080},
+		200: {6380},
+	})
+	uc := discovery.Propose(h)
+
+	if got, want := metricsReceiverIDs(uc), []string{"discovered_apache", "discovered_redis"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("metrics receivers = %v, want %v", got, want)
+	}
+	apache := uc.Metrics.Receivers["discovered_apache"].(*apps.MetricsReceiverApache)
+	if want := "http://localhost:8080/server-status?auto"; apache.ServerStatusURL != want {
+		t.Errorf("apache server_status_url = %q, want %q", apache.ServerStatusURL, want)
+	}
+	redis := uc.Metrics.Receivers["discovered_redis"].(*apps.MetricsReceiverRedis)
+	if want := "localhost:6380"; redis.Address != want {
+		t.Errorf("redis address = %q, want %q", redis.Address, want)
+	}
+}
+
+func TestProposeDefaultPort(t *testing.T) {
+	h := newHost([]discovery.Process{
+		{PID: 200, PPID: 1, Name: "redis-server", Cmdline: []string{"/usr/bin/redis-server"}},
+	}, map[int32][]int{})
+	uc := discovery.Propose(h)
+
+	redis := uc.Metrics.Receivers["discovered_redis"].(*apps.MetricsReceiverRedis)
+	if want := "localhost:6379"; redis.Address != want {
+		t.Errorf("redis address = %q, want %q", redis.Address, want)
+	}
+}
+
+func TestProposeJMXPortFromCmdline(t *testing.T) {
+	h := newHost([]discovery.Process{
+		{PID: 400, PPID: 1, Name: "java", Cmdline: []string{"java", "-Dcom.sun.management.jmxremote.port=9010", "-jar", "app.jar"}},
+		// Without remote JMX there is nothing to monitor.
+		{PID: 401, PPID: 1, Name: "java", Cmdline: []string{"java", "-jar", "other.jar"}},
+		// A port that is not a number is ignored.
+		{PID: 402, PPID: 1, Name: "java", Cmdline: []string{"java", "-Dcom.sun.management.jmxremote.port=abc", "-jar", "bad.jar"}},
+	}, map[int32][]int{})
+	uc := discovery.Propose(h)
+
+	if got, want := metricsReceiverIDs(uc), []string{"discovered_jvm"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("metrics receivers = %v, want %v", got, want)
+	}
+	jvm := uc.Metrics.Receivers["discovered_jvm"].(*apps.MetricsReceiverJVM)
+	if want := "localhost:9010"; jvm.Endpoint != want {
+		t.Errorf("jvm endpoint = %q, want %q", jvm.Endpoint, want)
+	}
+}
+
+func TestProposeJVMConflict(t *testing.T) {
+	h := newHost([]discovery.Process{
+		{PID: 300, PPID: 1, Name: "java", Cmdline: []string{"java", "-Dcassandra.jmx.local.port=7299", "org.apache.cassandra.service.CassandraDaemon"}},
+		{PID: 400, PPID: 1, Name: "java", Cmdline: []string{"java", "-Dcom.sun.management.jmxremote.port=9010", "-jar", "app.jar"}},
+	}, map[int32][]int{})
+	uc := discovery.Propose(h)
+
+	// Cassandra and the generic JVM share the JMX metrics receiver, so only Cassandra is monitored.
+	if got, want := metricsReceiverIDs(uc), []string{"discovered_cassandra"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("metrics receivers = %v, want %v", got, want)
+	}
+	cassandra := uc.Metrics.Receivers["discovered_cassandra"].(*apps.MetricsReceiverCassandra)
+	if want := "localhost:7299"; cassandra.Endpoint != want {
+		t.Errorf("cassandra endpoint = %q, want %q", cassandra.Endpoint, want)
+	}
+	if _, ok := uc.Metrics.Service.Pipelines["discovered_jvm"]; ok {
+		t.Errorf("got a discovered_jvm pipeline, want none")
+	}
+}
+
+func TestProposeMissingLogFiles(t *testing.T) {
+	processes := []discovery.Process{
+		{PID: 200, PPID: 1, Name: "redis-server", Cmdline: []string{"/usr/bin/redis-server"}},
+	}
+
+	uc := discovery.Propose(newHost(processes, map[int32][]int{}))
+	if len(uc.Logging.Receivers) != 0 || len(uc.Logging.Service.Pipelines) != 0 {
+		t.Errorf("got logging receivers %v, want none when no log file exists", uc.Logging.Receivers)
+	}
+	if _, ok := uc.Metrics.Receivers["discovered_redis"]; !ok {
+		t.Errorf("missing log files must not prevent the metrics receiver from being proposed")
+	}
+
+	uc = discovery.Propose(newHost(processes, map[int32][]int{}, "/var/log/redis_6379.log"))
+	redis, ok := uc.Logging.Receivers["discovered_redis"].(*apps.LoggingReceiverRedis)
+	if !ok {
+		t.Fatalf("got logging receivers %v, want discovered_redis", uc.Logging.Receivers)
+	}
+	if want := []string{"/var/log/redis_6379.log"}; !reflect.DeepEqual(redis.IncludePaths, want) {
+		t.Errorf("redis include_paths = %v, want %v", redis.IncludePaths, want)
+	}
+}
+
+func TestProposeNothingRunning(t *testing.T) {
+	uc := discovery.Propose(newHost(nil, map[int32][]int{}, "/var/log/redis_6379.log"))
+	if len(uc.Metrics.Receivers) != 0 || len(uc.Logging.Receivers) != 0 {
+		t.Errorf("got receivers for applications that are not running: %v %v", uc.Metrics.Receivers, uc.Logging.Receivers)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/shirou/gopsutil/net"
+	"github.com/shirou/gopsutil/process"
+)
+
+// Inspect takes a snapshot of the processes and listening ports on the current host.
+// Processes owned by other users are only visible when running as root.
+func Inspect() (*Host, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the host name: %w", err)
+	}
+	h := &Host{
+		Hostname:       hostname,
+		ListeningPorts: map[int32][]int{},
+		Glob:           filepath.Glob,
+	}
+
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	for _, p := range procs {
+		name, err := p.Name()
+		if err != nil {
+			// The process exited while we were looking at it.
+			continue
+		}
+		ppid, _ := p.Ppid()
+		cmdline, _ := p.CmdlineSlice()
+		h.Processes = append(h.Processes, Process{
+			PID:     p.Pid,
+			PPID:    ppid,
+			Name:    name,
+			Cmdline: cmdline,
+		})
+	}
+
+	conns, err := net.Connections("tcp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list listening ports: %w", err)
+	}
+	for _, c := range conns {
+		if c.Status == "LISTEN" && c.Pid != 0 {
+			h.ListeningPorts[c.Pid] = append(h.ListeningPorts[c.Pid], int(c.Laddr.Port))
+		}
+	}
+	return h, nil
+}
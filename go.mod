@@ -12,5 +12,6 @@ require (
 	github.com/mitchellh/mapstructure v1.4.2
 	github.com/shirou/gopsutil v3.21.8+incompatible
 	github.com/tklauser/go-sysconf v0.3.6 // indirect
+	github.com/yuin/gopher-lua v1.1.1
 	golang.org/x/sys v0.0.0-20210908233432-aa78b53d3365
 )